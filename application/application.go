@@ -9,17 +9,26 @@ import (
 	"os"
 	"os/signal"
 	"prolific/config"
-	"prolific/debug"
+	"prolific/features/admin"
 	"prolific/features/common"
 	"prolific/features/log"
 	"prolific/features/web-hook"
+	"prolific/logger"
+	"prolific/provider"
+	"prolific/provider/bitbucket"
+	"prolific/provider/gitea"
+	"prolific/provider/github"
+	"prolific/provider/gitlab"
+	"prolific/queue"
+	"strconv"
+	"syscall"
 	"time"
 )
 
 var (
 	elapsed time.Duration
 	shutdownTimeout = flag.Duration("shutdown-timeout", 20 * time.Second,
-	"shutdown timeout (5s,5m,5h) before connections are cancelled")
+	"shutdown timeout (5s,5m,5h) before connections, then running deployments, are cancelled")
 )
 
 func init() {
@@ -33,6 +42,9 @@ type Application struct {
 }
 
 func NewWithName(name string) *Application {
+	if err := logger.Configure(); err != nil {
+		logger.Fatal("Invalid log configuration", "error", err)
+	}
 	module := config.Config.OnModule(name)
 	address := fmt.Sprintf("%s:%s",
 		module.GetWithDefault("host", "127.0.0.1"),
@@ -44,60 +56,86 @@ func NewWithName(name string) *Application {
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
-	debug.Printf("Application named %s initialized.", name)
+	logger.Info("Application initialized", "name", name)
 	return &Application{ name, router, server }
 }
 
 func (app *Application) AddRoute(pathPrefix string, route common.IRoute) *Application {
 	subRouter := app.router.PathPrefix(pathPrefix).Subrouter()
-	debug.Printf("Added: Route of %s", pathPrefix)
+	logger.Debug("Route added", "path", pathPrefix)
 	route.Initialise(subRouter)
 	return app
 }
 
 func (app *Application) ListenAndServe() {
-	stop := make(chan os.Signal)
-	signal.Notify(stop, os.Interrupt)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	start := time.Now()
 
+	workers, err := strconv.Atoi(config.GetWithDefault("Prolific", "Workers", "2"))
+	if err != nil {
+		logger.Warn("Invalid number of workers, using 1 worker", "workers", config.Get("Prolific", "Workers"))
+		workers = 1
+	}
+	if err := common.OpenLogStore(); err != nil {
+		logger.Fatal("Log store error, shutdown the server", "error", err)
+	}
+	queue.Start(workers)
+	common.StartCompactor()
+
 	go func() {
-		debug.Printf("Server listening on %s\n", app.server.Addr)
+		logger.Info("Server listening", "address", app.server.Addr)
 		if err := app.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			elapsed = time.Since(start)
-			debug.Println("Server error, shutdown the server")
-			debug.Println("Error: " + err.Error())
+			logger.Error("Server error, shutdown the server", "error", err)
 			app.shutDown(1)
 		}
 	}()
 
 	<-stop
 	elapsed = time.Since(start)
-	debug.Println("Stop command received, gracefully shutdown the server")
+	logger.Info("Stop command received, gracefully shutdown the server")
 	app.shutDown(0)
 }
 
 func (app *Application) RegisterRoutes() *Application {
+	// List of providers
+	providers := []provider.Provider{
+		github.New(),
+		gitlab.New(),
+		gitea.New(),
+		bitbucket.New(),
+	}
 	// List of routes
-	app.AddRoute("/log", log.New())
-	app.AddRoute("/web-hook", web_hook.New())
+	app.AddRoute("/log", log.New(providers...))
+	app.AddRoute("/web-hook", web_hook.New(providers...))
+	app.AddRoute("/deploy", web_hook.NewDeploy(providers...))
+	app.AddRoute("/admin", admin.New())
 	// Not found handler
 	app.router.NotFoundHandler = http.HandlerFunc(common.NotFoundHandler)
 	return app
 }
 
 func (app *Application) shutDown(code int) {
-	debug.Printf("Waiting maximum %s for the server to shutdown", (*shutdownTimeout).String())
-
-	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
-	defer cancel()
+	logger.Info("Waiting for the server and running deployments to shutdown", "timeout", (*shutdownTimeout).String())
 
-	if err := app.server.Shutdown(ctx); err != nil {
-		debug.Println("Server shutdown error.")
-		debug.Println(err.Error())
+	// Connections and deployments are each given the timeout, so that a slow connection
+	// never leaves running deployments without time to finish.
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancelServer()
+	if err := app.server.Shutdown(serverCtx); err != nil {
+		logger.Error("Server shutdown error", "error", err)
 	}
 
-	debug.Println("Server down")
-	debug.Printf("Application Up-Time: %s\n", elapsed.String())
+	queueCtx, cancelQueue := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancelQueue()
+	queue.Stop(queueCtx)
+
+	common.StopCompactor()
+	common.CloseLogStore()
+
+	logger.Info("Server down", "uptime", elapsed.String())
+	logger.Close()
 	os.Exit(code)
 }
\ No newline at end of file
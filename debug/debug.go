@@ -1,29 +0,0 @@
-// +build DEBUG
-
-package debug
-
-import "log"
-
-func Print(v ...interface{}) {
-	log.Print(v...)
-}
-
-func Printf(format string, v ...interface{}) {
-	log.Printf(format, v...)
-}
-
-func Println(v ...interface{}) {
-	log.Println(v...)
-}
-
-func Fatal(v ...interface{}) {
-	log.Fatal(v...)
-}
-
-func Fatalf(format string, v ...interface{}) {
-	log.Fatalf(format, v...)
-}
-
-func Fatalln(v ...interface{}) {
-	log.Fatalln(v...)
-}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"net/http"
+	"prolific/features/common"
+	"prolific/logger"
+)
+
+// compact applies the configured retention policy right away, authorized with the
+// PROLIFIC_ADMIN_ACCESS_TOKEN, and responds with what was removed and archived.
+func compact(writer http.ResponseWriter, request *http.Request) {
+
+	if !common.Authorize(writer, request, "Prolific", "Admin_Access_Token") {
+		return
+	}
+
+	response := common.CreateResponse()
+
+	report, err := common.Compact(common.ConfiguredRetentionPolicy())
+	if err != nil {
+		logger.Error("Log compaction failed", "error", err)
+		statusCode := http.StatusInternalServerError
+		response.SetError(common.CreateError(statusCode, "Failed to compact logs."))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+
+	response.Message = "Logs compacted."
+	response.Data = report
+	common.SendResponse(writer, response)
+
+}
@@ -0,0 +1,16 @@
+package admin
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func New() Route {
+	return Route{}
+}
+
+type Route struct {}
+
+func (route Route) Initialise(r *mux.Router) {
+	r.Path("/compact").Methods(http.MethodPost).HandlerFunc(compact)
+}
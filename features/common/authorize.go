@@ -0,0 +1,50 @@
+package common
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"prolific/config"
+	"strings"
+)
+
+// Authorize checks the "Authorization: Token <token>" header against the access token
+// configured under tokenKey in the module, such as "Log_Access_Token", sending the
+// error response and returning false on failure.
+func Authorize(writer http.ResponseWriter, request *http.Request, moduleName string, tokenKey string) bool {
+
+	response := CreateResponse()
+
+	authorizationHeader := request.Header.Get("Authorization")
+	if authorizationHeader == "" {
+		statusCode := http.StatusUnauthorized
+		response.SetError(CreateError(statusCode, "No authorization provided."))
+		SendResponseWithStatusCode(writer, response, statusCode)
+		return false
+	}
+
+	authorization := strings.Split(authorizationHeader, " ")
+	if len(authorization) != 2 {
+		statusCode := http.StatusUnauthorized
+		response.SetError(CreateError(statusCode, "Authorization format invalid."))
+		SendResponseWithStatusCode(writer, response, statusCode)
+		return false
+	}
+
+	if strings.ToLower(authorization[0]) != "token" {
+		statusCode := http.StatusUnauthorized
+		response.SetError(CreateError(statusCode, "Authorization type invalid."))
+		SendResponseWithStatusCode(writer, response, statusCode)
+		return false
+	}
+
+	accessToken := config.Get(moduleName, tokenKey)
+	if accessToken == "" || subtle.ConstantTimeCompare([]byte(authorization[1]), []byte(accessToken)) != 1 {
+		statusCode := http.StatusUnauthorized
+		response.SetError(CreateError(statusCode, "Authorization token invalid."))
+		SendResponseWithStatusCode(writer, response, statusCode)
+		return false
+	}
+
+	return true
+
+}
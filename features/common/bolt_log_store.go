@@ -0,0 +1,370 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"prolific/logger"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The bucket of a LogType holds its logs keyed by their sequence number, which orders
+// them as they were recorded, the sequence numbers of their IDs, and an index for each
+// field logs are queried by. Index keys are the indexed value, a zero byte and the
+// sequence number of the log, or, for start times, the big endian Unix time in
+// nanoseconds and the sequence number.
+var (
+	logsBucket			= []byte("logs")
+	idsBucket			= []byte("ids")
+	ownersBucket		= []byte("owners")
+	repositoriesBucket	= []byte("repositories")
+	branchesBucket		= []byte("branches")
+	statusesBucket		= []byte("statuses")
+	timesBucket			= []byte("times")
+)
+
+// boltLogStore keeps logs in a bbolt database, indexed by owner, repository, branch,
+// status and start time. The logs of the journal of a LogType are imported the first
+// time the LogType is used.
+type boltLogStore struct {
+	db			*bbolt.DB
+	mutex		sync.Mutex
+	prepared	map[LogType]bool
+}
+
+func openBoltLogStore(path string) (*boltLogStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{ Timeout: 5 * time.Second })
+	if err != nil {
+		return nil, err
+	}
+	return &boltLogStore{ db: db, prepared: map[LogType]bool{} }, nil
+}
+
+// prepare creates the bucket of logType, importing the logs of its journal, unless it
+// already exists.
+func (store *boltLogStore) prepare(logType LogType) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.prepared[logType] {
+		return nil
+	}
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(logType)) != nil {
+			return nil
+		}
+		bucket, err := tx.CreateBucket([]byte(logType))
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{ logsBucket, idsBucket, ownersBucket, repositoriesBucket, branchesBucket, statusesBucket, timesBucket } {
+			if _, err := bucket.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		imported := 0
+		err = (&fileLogStore{}).Query(logType, LogQuery{}, func(log *Log) bool {
+			if err = putLog(bucket, *log); err != nil {
+				return false
+			}
+			imported++
+			return true
+		})
+		if err == nil && imported > 0 {
+			logger.Info("Journal imported into the log database", "log_type", logType, "journal", journalPath(logType), "logs", imported)
+		}
+		return err
+	})
+	if err == nil {
+		store.prepared[logType] = true
+	}
+	return err
+}
+
+func (store *boltLogStore) Append(logType LogType, log Log) error {
+	if err := store.prepare(logType); err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bbolt.Tx) error {
+		return putLog(tx.Bucket([]byte(logType)), log)
+	})
+}
+
+func putLog(bucket *bbolt.Bucket, log Log) error {
+	content, err := log.marshalWithID()
+	if err != nil {
+		return err
+	}
+	logs := bucket.Bucket(logsBucket)
+	sequence, err := logs.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := sequenceKey(sequence)
+	if err := logs.Put(key, content); err != nil {
+		return err
+	}
+	if err := bucket.Bucket(idsBucket).Put([]byte(log.ID), key); err != nil {
+		return err
+	}
+	for name, value := range logIndexes(&log) {
+		if err := bucket.Bucket([]byte(name)).Put(indexKey([]byte(value), key), nil); err != nil {
+			return err
+		}
+	}
+	if startedAt, err := log.startTime(); err == nil {
+		return bucket.Bucket(timesBucket).Put(append(timeKey(startedAt), key...), nil)
+	}
+	return nil
+}
+
+// logIndexes returns the values under which the log is indexed, by index name.
+func logIndexes(log *Log) map[string]string {
+	indexes := map[string]string{ string(statusesBucket): strconv.FormatBool(log.Success) }
+	if log.Data != nil {
+		indexes[string(ownersBucket)] = log.Data.Owner
+		indexes[string(repositoriesBucket)] = log.Data.Repository
+		indexes[string(branchesBucket)] = log.Data.Branch
+	}
+	return indexes
+}
+
+func (store *boltLogStore) Query(logType LogType, query LogQuery, visit func(log *Log) bool) error {
+	if err := store.prepare(logType); err != nil {
+		return err
+	}
+	return store.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(logType))
+		logs := bucket.Bucket(logsBucket)
+
+		var after []byte
+		if query.After != "" {
+			after = bucket.Bucket(idsBucket).Get([]byte(query.After))
+			if after == nil {
+				return nil
+			}
+		}
+
+		var next func() ([]byte, []byte)
+		if keys, indexed := queryKeys(bucket, query); indexed {
+			if query.Descending {
+				for i, j := 0, len(keys) - 1; i < j; i, j = i + 1, j - 1 {
+					keys[i], keys[j] = keys[j], keys[i]
+				}
+			}
+			if after != nil {
+				var kept [][]byte
+				for _, key := range keys {
+					if comparison := bytes.Compare(key, after); (comparison > 0) != query.Descending && comparison != 0 {
+						kept = append(kept, key)
+					}
+				}
+				keys = kept
+			}
+			next = func() ([]byte, []byte) {
+				if len(keys) == 0 {
+					return nil, nil
+				}
+				key := keys[0]
+				keys = keys[1:]
+				return key, logs.Get(key)
+			}
+		} else {
+			cursor := logs.Cursor()
+			var key, value []byte
+			switch {
+			case after == nil && !query.Descending:
+				key, value = cursor.First()
+			case after == nil:
+				key, value = cursor.Last()
+			case !query.Descending:
+				cursor.Seek(after)
+				key, value = cursor.Next()
+			default:
+				cursor.Seek(after)
+				key, value = cursor.Prev()
+			}
+			next = func() ([]byte, []byte) {
+				currentKey, currentValue := key, value
+				if query.Descending {
+					key, value = cursor.Prev()
+				} else {
+					key, value = cursor.Next()
+				}
+				return currentKey, currentValue
+			}
+		}
+
+		for key, value := next(); key != nil; key, value = next() {
+			if value == nil {
+				continue
+			}
+			var log Log
+			if err := json.Unmarshal(value, &log); err != nil {
+				return err
+			}
+			log.ensureID(value)
+			if query.matches(&log) && !visit(&log) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// queryKeys returns the keys, in order, of the logs selected by the most selective
+// index the query uses, and false when the query uses none.
+func queryKeys(bucket *bbolt.Bucket, query LogQuery) ([][]byte, bool) {
+	var keys [][]byte
+	var index *bbolt.Bucket
+	var value string
+	switch {
+	case query.Branch != "":
+		index, value = bucket.Bucket(branchesBucket), query.Branch
+	case query.Repository != "":
+		index, value = bucket.Bucket(repositoriesBucket), query.Repository
+	case query.Owner != "":
+		index, value = bucket.Bucket(ownersBucket), query.Owner
+	case query.Success != nil:
+		index, value = bucket.Bucket(statusesBucket), strconv.FormatBool(*query.Success)
+	case !query.Since.IsZero() || !query.Until.IsZero():
+		cursor := bucket.Bucket(timesBucket).Cursor()
+		var key []byte
+		if query.Since.IsZero() {
+			key, _ = cursor.First()
+		} else {
+			key, _ = cursor.Seek(timeKey(query.Since))
+		}
+		for ; key != nil; key, _ = cursor.Next() {
+			if !query.Until.IsZero() && bytes.Compare(key[:8], timeKey(query.Until)) >= 0 {
+				break
+			}
+			keys = append(keys, key[len(key)-8:])
+		}
+		sort.Slice(keys, func(i int, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		return keys, true
+	default:
+		return nil, false
+	}
+	prefix := indexKey([]byte(value), nil)
+	cursor := index.Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		keys = append(keys, key[len(prefix):])
+	}
+	return keys, true
+}
+
+func (store *boltLogStore) Find(logType LogType, id string) (*Log, error) {
+	if err := store.prepare(logType); err != nil {
+		return nil, err
+	}
+	var found *Log
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(logType))
+		key := bucket.Bucket(idsBucket).Get([]byte(id))
+		if key == nil {
+			return nil
+		}
+		value := bucket.Bucket(logsBucket).Get(key)
+		if value == nil {
+			return nil
+		}
+		var log Log
+		if err := json.Unmarshal(value, &log); err != nil {
+			return err
+		}
+		found = &log
+		return nil
+	})
+	return found, err
+}
+
+func (store *boltLogStore) Remove(logType LogType, ids map[string]bool) (int, error) {
+	if err := store.prepare(logType); err != nil {
+		return 0, err
+	}
+	removed := 0
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(logType))
+		logs := bucket.Bucket(logsBucket)
+		for id := range ids {
+			key := bucket.Bucket(idsBucket).Get([]byte(id))
+			if key == nil {
+				continue
+			}
+			key = append([]byte{}, key...)
+			value := logs.Get(key)
+			if value == nil {
+				continue
+			}
+			var log Log
+			if err := json.Unmarshal(value, &log); err != nil {
+				return err
+			}
+			for name, indexValue := range logIndexes(&log) {
+				if err := bucket.Bucket([]byte(name)).Delete(indexKey([]byte(indexValue), key)); err != nil {
+					return err
+				}
+			}
+			if startedAt, err := log.startTime(); err == nil {
+				if err := bucket.Bucket(timesBucket).Delete(append(timeKey(startedAt), key...)); err != nil {
+					return err
+				}
+			}
+			if err := bucket.Bucket(idsBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+			if err := logs.Delete(key); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (store *boltLogStore) LogTypes() ([]LogType, error) {
+	var logTypes []LogType
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			logTypes = append(logTypes, LogType(name))
+			return nil
+		})
+	})
+	return logTypes, err
+}
+
+func (store *boltLogStore) Close() error {
+	return store.db.Close()
+}
+
+func sequenceKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+func timeKey(value time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(value.UnixNano()))
+	return key
+}
+
+// indexKey returns the key of the log at key in the index of a value, or the prefix of
+// the keys of the value when key is nil.
+func indexKey(value []byte, key []byte) []byte {
+	indexKey := append(append([]byte{}, value...), 0)
+	return append(indexKey, key...)
+}
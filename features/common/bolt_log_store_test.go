@@ -0,0 +1,129 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStores returns a journal and a bolt store holding the same logs: l0 to l9, started
+// an hour apart, of repositories shop and blog in turn, every third one failed, and the
+// last ones on branch dev.
+func testStores(t *testing.T) map[string]LogStore {
+	dir := useLogDir(t)
+	boltStore, err := openBoltLogStore(filepath.Join(dir, "prolific.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { boltStore.Close() })
+	stores := map[string]LogStore{ "file": &fileLogStore{}, "bolt": boltStore }
+	// The database imports the journal when first used, which must then be empty.
+	if err := boltStore.prepare("Paged"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		repository := "shop"
+		if i % 2 == 1 {
+			repository = "blog"
+		}
+		branch := "main"
+		if i >= 7 {
+			branch = "dev"
+		}
+		log := Log{
+			ID:        fmt.Sprintf("l%d", i),
+			Success:   i % 3 != 0,
+			StartedAt: start.Add(time.Duration(i) * time.Hour).Format(time.RFC1123),
+			Data:      &LogData{ Owner: "acme", Repository: repository, Branch: branch },
+		}
+		for _, store := range stores {
+			if err := store.Append("Paged", log); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return stores
+}
+
+func TestQueryIndexes(t *testing.T) {
+	failed := false
+	tests := []struct {
+		name	string
+		query	LogQuery
+		want	string
+	}{
+		{ "all", LogQuery{}, "l0,l1,l2,l3,l4,l5,l6,l7,l8,l9" },
+		{ "repository", LogQuery{ Repository: "blog" }, "l1,l3,l5,l7,l9" },
+		{ "owner and branch", LogQuery{ Owner: "acme", Branch: "dev" }, "l7,l8,l9" },
+		{ "repository and branch", LogQuery{ Repository: "shop", Branch: "dev" }, "l8" },
+		{ "unknown owner", LogQuery{ Owner: "other" }, "" },
+		{ "failed", LogQuery{ Success: &failed }, "l0,l3,l6,l9" },
+		{ "failed of repository", LogQuery{ Repository: "shop", Success: &failed }, "l0,l6" },
+		{ "time range", LogQuery{
+			Since: time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+			Until: time.Date(2026, 1, 1, 5, 0, 0, 0, time.UTC),
+		}, "l2,l3,l4" },
+		{ "descending", LogQuery{ Repository: "blog", Descending: true }, "l9,l7,l5,l3,l1" },
+		{ "after", LogQuery{ Repository: "blog", After: "l3" }, "l5,l7,l9" },
+		{ "after descending", LogQuery{ Repository: "blog", After: "l5", Descending: true }, "l3,l1" },
+		{ "after without index", LogQuery{ After: "l7" }, "l8,l9" },
+		{ "after without index descending", LogQuery{ After: "l2", Descending: true }, "l1,l0" },
+		{ "after unknown log", LogQuery{ After: "unknown" }, "" },
+	}
+	for name, store := range testStores(t) {
+		for _, test := range tests {
+			if got := ids(queryAll(t, store, "Paged", test.query)); got != test.want {
+				t.Errorf("%s store, %s: expected %s, got %s", name, test.name, test.want, got)
+			}
+		}
+	}
+}
+
+func TestQueryLogPage(t *testing.T) {
+	previous := logStore
+	defer func() { logStore = previous }()
+
+	for name, store := range testStores(t) {
+		logStore = store
+		for _, descending := range []bool{ false, true } {
+			query := LogQuery{ Repository: "shop", Descending: descending }
+			var pages []string
+			for {
+				logs, next := QueryLogPage("Paged", query, 2)
+				pages = append(pages, ids(logs))
+				if next == "" {
+					break
+				}
+				query.After = next
+			}
+			want := "[l0,l2 l4,l6 l8]"
+			if descending {
+				want = "[l8,l6 l4,l2 l0]"
+			}
+			if got := fmt.Sprint(pages); got != want {
+				t.Errorf("%s store, descending %v: expected pages %s, got %s", name, descending, want, got)
+			}
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for name, store := range testStores(t) {
+		removed, err := store.Remove("Paged", map[string]bool{ "l1": true, "l4": true, "unknown": true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if removed != 2 {
+			t.Errorf("%s store: expected 2 logs removed, got %d", name, removed)
+		}
+		if got := ids(queryAll(t, store, "Paged", LogQuery{ Repository: "blog" })); got != "l3,l5,l7,l9" {
+			t.Errorf("%s store: expected the removed logs to leave the indexes, got %s", name, got)
+		}
+		if log, _ := store.Find("Paged", "l4"); log != nil {
+			t.Errorf("%s store: expected l4 to be removed", name)
+		}
+	}
+}
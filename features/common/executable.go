@@ -1,36 +1,191 @@
 package common
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+// outputWaitDelay is how long the outputs are still read once the executable exited or
+// was killed. Processes it left running in the background, such as a daemon it started,
+// may keep the outputs open for as long as they live, and are not waited for.
+const outputWaitDelay = time.Second
+
 type Executable struct {
-	Name 				string	`json:"name"`
-	Path				string	`json:"path"`
-	WorkingDirectory	string	`json:"working_directory"`
+	Name 				string			`json:"name"`
+	Path				string			`json:"path"`
+	WorkingDirectory	string			`json:"working_directory"`
+	Env					[]string		`json:"env,omitempty"`
+	Timeout				time.Duration	`json:"timeout,omitempty"`
+	// User is the account the executable runs as, instead of the account of Prolific.
+	User				string			`json:"user,omitempty"`
+	// OutputLimit is the number of bytes kept of each output, the last ones, or 0 to
+	// keep all of them.
+	OutputLimit			int				`json:"output_limit,omitempty"`
+	// OnLine is passed each line written to stdout, as "stdout", or to stderr, as
+	// "stderr", while the executable runs.
+	OnLine				func(stream string, line string)	`json:"-"`
 }
 
 func (executable *Executable) Exists() bool {
-	executablePath, err := exec.LookPath(executable.Name)
+	executablePath, err := lookPath(executable.Name, executable.WorkingDirectory)
 	if err != nil || executablePath == "" {
 		return false
 	}
 	return true
 }
 
-func (executable *Executable) Run(args ...string) (string, error) {
-	command := &exec.Cmd{
-		Path:         executable.Path,
-		Args:         append([]string{ executable.Path }, args...),
-		Env:          os.Environ(),
-		Dir:          executable.WorkingDirectory,
+// Run runs the executable, as its user when it has one, until it exits, ctx is done or
+// its timeout elapses, in which case the executable and every process it spawned are
+// killed. Processes it leaves running in the background are not waited for. The error
+// of a timeout is "timed out after <timeout>". The log records its outputs, exit code
+// and timing.
+func (executable *Executable) Run(ctx context.Context, args ...string) (ExecutableLog, error) {
+	executableLog := ExecutableLog{
+		Name:    executable.Path,
+		Args:    strings.Join(append([]string{ executable.Path }, args...), " "),
+		WorkDir: executable.WorkingDirectory,
+	}
+
+	command := exec.Command(executable.Path, args...)
+	command.Env = os.Environ()
+	var account *user.User
+	if executable.User != "" {
+		var err error
+		account, err = user.Lookup(executable.User)
+		if err != nil {
+			executableLog.Error = err.Error()
+			return executableLog, err
+		}
+		command.Env = append(command.Env,
+			"HOME=" + account.HomeDir, "USER=" + account.Username, "LOGNAME=" + account.Username)
+	}
+	command.Env = append(command.Env, executable.Env...)
+	command.Dir = executable.WorkingDirectory
+	if err := configureProcess(command, account); err != nil {
+		executableLog.Error = err.Error()
+		return executableLog, err
+	}
+
+	runCtx := ctx
+	if executable.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, executable.Timeout)
+		defer cancel()
+	}
+	output := newOutputBuffer(executable.OutputLimit)
+	stdout := newOutputBuffer(executable.OutputLimit)
+	stderr := newOutputBuffer(executable.OutputLimit)
+	stdoutWriter := &outputWriter{ stream: stdout, combined: output, name: "stdout", onLine: executable.OnLine }
+	stderrWriter := &outputWriter{ stream: stderr, combined: output, name: "stderr", onLine: executable.OnLine }
+	stdoutPipe, err := command.StdoutPipe()
+	if err != nil {
+		executableLog.Error = err.Error()
+		return executableLog, err
+	}
+	stderrPipe, err := command.StderrPipe()
+	if err != nil {
+		stdoutPipe.Close()
+		executableLog.Error = err.Error()
+		return executableLog, err
+	}
+
+	start := time.Now()
+	var state *os.ProcessState
+	err = command.Start()
+	if err == nil {
+		state, err = executable.wait(runCtx, command, stdoutWriter, stdoutPipe, stderrWriter, stderrPipe)
+	} else {
+		stdoutPipe.Close()
+		stderrPipe.Close()
+	}
+	end := time.Now()
+	stdoutWriter.flush()
+	stderrWriter.flush()
+
+	executableLog.Output = output.String()
+	executableLog.Stdout = stdout.String()
+	executableLog.Stderr = stderr.String()
+	executableLog.StartedAt = start.Format(time.RFC1123)
+	executableLog.EndedAt = end.Format(time.RFC1123)
+	executableLog.TimeElapsed = end.Sub(start).String()
+	executableLog.Truncated = output.truncated() || stdout.truncated() || stderr.truncated()
+	if state != nil {
+		exitCode := state.ExitCode()
+		executableLog.ExitCode = &exitCode
+	}
+
+	if runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("timed out after %s", executable.Timeout)
+	} else if ctx.Err() != nil {
+		err = ctx.Err()
 	}
-	output, err := command.Output()
 	if err != nil {
-		return string(output), err
+		executableLog.Error = err.Error()
 	}
-	return string(output), nil
+	return executableLog, err
+}
+
+// wait copies the outputs of the started command until it exits, or kills it with every
+// process of its group once ctx is done, and returns how it exited. The outputs are read
+// for outputWaitDelay more at most, after which they are closed, so that processes left
+// running in the background do not hold up the executable.
+func (executable *Executable) wait(ctx context.Context, command *exec.Cmd,
+	stdoutWriter io.Writer, stdoutPipe io.ReadCloser, stderrWriter io.Writer, stderrPipe io.ReadCloser) (*os.ProcessState, error) {
+
+	copied := make(chan struct{}, 2)
+	for _, output := range []struct {
+		writer	io.Writer
+		pipe	io.Reader
+	}{ { stdoutWriter, stdoutPipe }, { stderrWriter, stderrPipe } } {
+		go func(writer io.Writer, pipe io.Reader) {
+			_, _ = io.Copy(writer, pipe)
+			copied <- struct{}{}
+		}(output.writer, output.pipe)
+	}
+
+	type exit struct {
+		state	*os.ProcessState
+		err		error
+	}
+	exited := make(chan exit, 1)
+	go func() {
+		state, err := command.Process.Wait()
+		exited <- exit{ state, err }
+	}()
+
+	var result exit
+	select {
+	case result = <-exited:
+	case <-ctx.Done():
+		killProcessGroup(command)
+		result = <-exited
+	}
+
+	delay := time.NewTimer(outputWaitDelay)
+	defer delay.Stop()
+	for pending := 2; pending > 0; pending-- {
+		select {
+		case <-copied:
+		case <-delay.C:
+			stdoutPipe.Close()
+			stderrPipe.Close()
+			<-copied
+		}
+	}
+	stdoutPipe.Close()
+	stderrPipe.Close()
+
+	if result.err == nil && !result.state.Success() {
+		result.err = &exec.ExitError{ ProcessState: result.state }
+	}
+	return result.state, result.err
 }
 
 func NewExecutable(name string, workingDirectory string) (*Executable, error) {
@@ -41,17 +196,43 @@ func NewExecutable(name string, workingDirectory string) (*Executable, error) {
 		}
 		workingDirectory = workDir
 	}
-	executablePath, err := exec.LookPath(name)
+	executablePath, err := lookPath(name, workingDirectory)
 	if err != nil {
 		return nil, err
 	}
 	return &Executable{Name: name, Path: executablePath, WorkingDirectory: workingDirectory}, nil
 }
 
+// lookPath finds the executable name in PATH, unless it holds a path separator, such as
+// a script of the repository, in which case it is found relative to workingDirectory
+// rather than to the directory Prolific runs in.
+func lookPath(name string, workingDirectory string) (string, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(workingDirectory, name)
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// ExecutableLog is the record of a step of a deployment. Output interleaves Stdout and
+// Stderr as they were written. ExitCode is only set once the executable has exited.
 type ExecutableLog struct {
-	Name	string	`json:"name"`
-	Args	string	`json:"args"`
-	WorkDir	string	`json:"work_dir"`
-	Output	string	`json:"output"`
-	Error	string	`json:"error,omitempty"`
-}
\ No newline at end of file
+	Step			string	`json:"step,omitempty"`
+	Name			string	`json:"name"`
+	Args			string	`json:"args"`
+	WorkDir			string	`json:"work_dir"`
+	Output			string	`json:"output"`
+	Stdout			string	`json:"stdout,omitempty"`
+	Stderr			string	`json:"stderr,omitempty"`
+	ExitCode		*int	`json:"exit_code,omitempty"`
+	StartedAt		string	`json:"started_at,omitempty"`
+	EndedAt			string	`json:"ended_at,omitempty"`
+	TimeElapsed		string	`json:"time_elapsed,omitempty"`
+	Truncated		bool	`json:"truncated,omitempty"`
+	Error			string	`json:"error,omitempty"`
+	// FirstEventID and LastEventID are the IDs of the step and step_end events the step
+	// was streamed with, 0 when it was not.
+	FirstEventID	int		`json:"first_event_id,omitempty"`
+	LastEventID		int		`json:"last_event_id,omitempty"`
+}
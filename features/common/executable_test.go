@@ -0,0 +1,121 @@
+// +build !windows
+
+package common
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shellExecutable(t *testing.T, timeout time.Duration) *Executable {
+	executable, err := NewExecutable("sh", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	executable.Timeout = timeout
+	return executable
+}
+
+func TestRunTimesOutDespiteDetachedChild(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	executable := shellExecutable(t, 500 * time.Millisecond)
+
+	start := time.Now()
+	executableLog, err := executable.Run(context.Background(), "-c", "setsid sleep 4 & sleep 10")
+	elapsed := time.Since(start)
+
+	if err == nil || err.Error() != "timed out after 500ms" {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if elapsed > 500 * time.Millisecond + outputWaitDelay + time.Second {
+		t.Fatalf("returned after %s, the detached child was waited for", elapsed)
+	}
+	if executableLog.Error != err.Error() {
+		t.Fatalf("expected the error to be logged, got %q", executableLog.Error)
+	}
+}
+
+func TestRunReturnsDespiteBackgroundedChild(t *testing.T) {
+	executable := shellExecutable(t, 0)
+
+	start := time.Now()
+	executableLog, err := executable.Run(context.Background(), "-c", "nohup sleep 3 & echo started")
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed > outputWaitDelay + time.Second {
+		t.Fatalf("returned after %s, the backgrounded child was waited for", elapsed)
+	}
+	if !strings.Contains(executableLog.Stdout, "started") {
+		t.Fatalf("expected the output to be kept, got %q", executableLog.Stdout)
+	}
+	if executableLog.ExitCode == nil || *executableLog.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %v", executableLog.ExitCode)
+	}
+}
+
+func TestRunReportsExitCode(t *testing.T) {
+	executable := shellExecutable(t, 0)
+
+	executableLog, err := executable.Run(context.Background(), "-c", "echo out; echo err >&2; exit 3")
+
+	if err == nil || err.Error() != "exit status 3" {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	if executableLog.ExitCode == nil || *executableLog.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %v", executableLog.ExitCode)
+	}
+	if executableLog.Stdout != "out\n" || executableLog.Stderr != "err\n" {
+		t.Fatalf("unexpected outputs %q and %q", executableLog.Stdout, executableLog.Stderr)
+	}
+}
+
+func TestRunScriptRelativeToWorkingDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "scripts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "scripts", "deploy.sh")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\necho deployed from \"$(pwd)\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The script is not found relative to the directory the test runs in.
+	for _, name := range []string{ "./scripts/deploy.sh", "scripts/deploy.sh" } {
+		executable, err := NewExecutable(name, dir)
+		if err != nil {
+			t.Fatalf("%s: expected the script of the working directory, got %v", name, err)
+		}
+		if !executable.Exists() {
+			t.Fatalf("%s: expected the script to exist", name)
+		}
+		executableLog, err := executable.Run(context.Background())
+		if err != nil || !strings.Contains(executableLog.Stdout, "deployed from") {
+			t.Fatalf("%s: expected the script to run, got %q, %v", name, executableLog.Stdout, err)
+		}
+	}
+
+	if err := os.Chmod(script, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewExecutable("./scripts/deploy.sh", dir); err == nil {
+		t.Fatal("expected a script that is not executable to be refused")
+	}
+	if _, err := NewExecutable("./scripts/missing.sh", dir); err == nil {
+		t.Fatal("expected a missing script to be refused")
+	}
+}
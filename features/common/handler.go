@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"prolific/config"
-	"prolific/debug"
+	"prolific/logger"
 )
 
 type Error struct {
@@ -13,10 +13,12 @@ type Error struct {
 }
 
 type Response struct {
-	Success	bool     `json:"success"`
-	Error 	*Error     `json:"error,omitempty"`
-	Message	string   `json:"message,omitempty"`
-	Data	interface{} `json:"data,omitempty"`
+	Success		bool		`json:"success"`
+	Error		*Error		`json:"error,omitempty"`
+	Message		string		`json:"message,omitempty"`
+	Data		interface{}	`json:"data,omitempty"`
+	// NextCursor is the cursor of the next page of Data, when there is one.
+	NextCursor	string		`json:"next_cursor,omitempty"`
 }
 
 func (response *Response) SetError(error *Error) *Response {
@@ -42,7 +44,7 @@ func SendResponseWithStatusCode(writer http.ResponseWriter, response *Response,
 	writer.WriteHeader(statusCode)
 	err := json.NewEncoder(writer).Encode(response)
 	if err != nil {
-		debug.Println(err)
+		logger.Debug("Response could not be sent", "error", err)
 	}
 }
 
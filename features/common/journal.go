@@ -0,0 +1,377 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"prolific/logger"
+	"strings"
+	"sync"
+)
+
+// fileLogStore keeps the logs of a provider in a journal, a JSON Lines file holding one
+// log per line, which is only ever appended to. Every log is synced to disk once
+// written, and a last line torn by a crash is dropped before the next log is appended.
+// Appends are serialized by a mutex within Prolific, and by a lock of the file across
+// processes.
+type fileLogStore struct{}
+
+var (
+	journalMutexes		= map[LogType]*sync.RWMutex{}
+	journalMutexesMutex	sync.Mutex
+)
+
+func journalPath(logType LogType) string {
+	return filepath.Join(logDirPath, fmt.Sprintf("%s.jsonl", logType))
+}
+
+// legacyLogPath is the path of the JSON array the logs were kept in before journals.
+func legacyLogPath(logType LogType) string {
+	return filepath.Join(logDirPath, fmt.Sprintf("%s.json", logType))
+}
+
+// journalMutex returns the mutex of the journal of logType, migrating the legacy logs
+// of logType into the journal the first time it is asked for.
+func journalMutex(logType LogType) *sync.RWMutex {
+	journalMutexesMutex.Lock()
+	defer journalMutexesMutex.Unlock()
+	mutex, ok := journalMutexes[logType]
+	if !ok {
+		mutex = &sync.RWMutex{}
+		journalMutexes[logType] = mutex
+		if err := migrateLegacyLogs(logType); err != nil {
+			logger.Error("Legacy logs could not be migrated", "log_type", logType, "error", err)
+		}
+	}
+	return mutex
+}
+
+// Append appends the log to the journal of logType and syncs it to disk.
+func (store *fileLogStore) Append(logType LogType, log Log) error {
+	line, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	mutex := journalMutex(logType)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	file, err := openLockedJournal(logType)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	defer unlockFile(file)
+
+	end, err := dropTornLine(file)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(line, end); err != nil {
+		// A partly written log is dropped before the next one is appended.
+		return err
+	}
+	return file.Sync()
+}
+
+// openLockedJournal opens the journal of logType, creating it when needed, and locks
+// it exclusively. As compactions replace the journal, the journal is opened again when
+// it was replaced while waiting for the lock.
+func openLockedJournal(logType LogType) (*os.File, error) {
+	if err := os.MkdirAll(logDirPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	for {
+		file, err := os.OpenFile(journalPath(logType), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(file, true); err != nil {
+			file.Close()
+			return nil, err
+		}
+		opened, err := file.Stat()
+		if err == nil {
+			var current os.FileInfo
+			current, err = os.Stat(journalPath(logType))
+			if err == nil && os.SameFile(opened, current) {
+				return file, nil
+			}
+		}
+		unlockFile(file)
+		file.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Remove rewrites the journal of logType without the logs whose IDs are given, and
+// returns how many logs were removed.
+func (store *fileLogStore) Remove(logType LogType, ids map[string]bool) (int, error) {
+	mutex := journalMutex(logType)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	file, err := openLockedJournal(logType)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	defer unlockFile(file)
+
+	temporaryPath := journalPath(logType) + ".tmp"
+	temporaryFile, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	reader := bufio.NewReader(file)
+	writer := bufio.NewWriter(temporaryFile)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr == io.EOF {
+			// A torn last line is dropped.
+			break
+		}
+		if readErr != nil {
+			err = readErr
+			break
+		}
+		var log Log
+		if json.Unmarshal(line, &log) == nil {
+			log.ensureID(line)
+			if ids[log.ID] {
+				removed++
+				continue
+			}
+		}
+		if _, err = writer.Write(line); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = temporaryFile.Sync()
+	}
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && removed > 0 {
+		err = os.Rename(temporaryPath, journalPath(logType))
+	}
+	if err != nil || removed == 0 {
+		os.Remove(temporaryPath)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// LogTypes returns the log types that have a journal.
+func (store *fileLogStore) LogTypes() ([]LogType, error) {
+	paths, err := filepath.Glob(filepath.Join(logDirPath, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var logTypes []LogType
+	for _, path := range paths {
+		logTypes = append(logTypes, LogType(strings.TrimSuffix(filepath.Base(path), ".jsonl")))
+	}
+	return logTypes, nil
+}
+
+// dropTornLine truncates the journal after its last complete line, and returns its
+// size.
+func dropTornLine(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	end := size
+	buffer := make([]byte, 4096)
+	for end > 0 {
+		chunk := int64(len(buffer))
+		if chunk > end {
+			chunk = end
+		}
+		if _, err := file.ReadAt(buffer[:chunk], end - chunk); err != nil {
+			return 0, err
+		}
+		if index := bytes.LastIndexByte(buffer[:chunk], '\n'); index >= 0 {
+			end = end - chunk + int64(index) + 1
+			break
+		}
+		end -= chunk
+	}
+	if end == size {
+		return size, nil
+	}
+	logger.Warn("Dropping a torn line at the end of the journal", "file", file.Name(), "bytes", size - end)
+	if err := file.Truncate(end); err != nil {
+		return 0, err
+	}
+	return end, file.Sync()
+}
+
+func (store *fileLogStore) Query(logType LogType, query LogQuery, visit func(log *Log) bool) error {
+	started := query.After == ""
+	visitAfter := func(log *Log) bool {
+		if !started {
+			started = log.ID == query.After
+			return true
+		}
+		return visit(log)
+	}
+	if !query.Descending {
+		return scanJournal(logType, func(log *Log) bool {
+			return !query.matches(log) || visitAfter(log)
+		})
+	}
+	var logs []*Log
+	err := scanJournal(logType, func(log *Log) bool {
+		if query.matches(log) {
+			logs = append(logs, log)
+		}
+		return true
+	})
+	for index := len(logs) - 1; index >= 0; index-- {
+		if !visitAfter(logs[index]) {
+			break
+		}
+	}
+	return err
+}
+
+func (store *fileLogStore) Find(logType LogType, id string) (*Log, error) {
+	var found *Log
+	err := scanJournal(logType, func(log *Log) bool {
+		if log.ID == id {
+			found = log
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+func (store *fileLogStore) Close() error {
+	return nil
+}
+
+// scanJournal passes the logs of the journal of logType to visit, oldest first, until
+// visit returns false. Lines that cannot be parsed, such as a torn last line, are
+// skipped.
+func scanJournal(logType LogType, visit func(log *Log) bool) error {
+	mutex := journalMutex(logType)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	file, err := os.Open(journalPath(logType))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	if err := lockFile(file, false); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	reader := bufio.NewReader(file)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var log Log
+		if err := json.Unmarshal(line, &log); err != nil {
+			logger.Warn("Skipping an unreadable journal line", "file", file.Name(), "line", number, "error", err)
+			continue
+		}
+		log.ensureID(line)
+		if !visit(&log) {
+			return nil
+		}
+	}
+}
+
+// migrateLegacyLogs moves the logs of the legacy JSON array of logType into a journal,
+// when there is no journal yet, and renames the array to <LogType>.json.migrated.
+func migrateLegacyLogs(logType LogType) error {
+	legacyPath := legacyLogPath(logType)
+	content, err := ioutil.ReadFile(legacyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := os.Stat(journalPath(logType)); err == nil {
+		logger.Warn("Legacy logs not migrated, the journal exists already", "legacy", legacyPath, "journal", journalPath(logType))
+		return nil
+	}
+
+	var logs Logs
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := json.Unmarshal(content, &logs); err != nil {
+			return fmt.Errorf("logs of %s could not be migrated: %s", legacyPath, err.Error())
+		}
+	}
+
+	temporaryPath := journalPath(logType) + ".tmp"
+	file, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, log := range logs {
+		line, err := log.marshalWithID()
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(temporaryPath)
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporaryPath, journalPath(logType))
+	}
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+	if err := os.Rename(legacyPath, legacyPath + ".migrated"); err != nil {
+		return err
+	}
+	logger.Info("Legacy logs migrated", "legacy", legacyPath, "journal", journalPath(logType), "logs", len(logs))
+	return nil
+}
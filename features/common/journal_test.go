@@ -0,0 +1,112 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testLog(id string, repository string) Log {
+	return Log{
+		ID:        id,
+		Success:   true,
+		StartedAt: "Mon, 02 Jan 2006 15:04:05 UTC",
+		Data:      &LogData{ Owner: "acme", Repository: repository, Branch: "main" },
+	}
+}
+
+func ids(logs Logs) string {
+	var ids []string
+	for _, log := range logs {
+		ids = append(ids, log.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTornLineIsDroppedBeforeAppending(t *testing.T) {
+	dir := useLogDir(t)
+	var logType LogType = "TornLine"
+	store := &fileLogStore{}
+
+	if err := store.Append(logType, testLog("first", "shop")); err != nil {
+		t.Fatal(err)
+	}
+	// A crash while appending leaves the start of a line behind.
+	path := filepath.Join(dir, "TornLine.jsonl")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"id":"torn","success":tr`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if logs := queryAll(t, store, logType, LogQuery{}); ids(logs) != "first" {
+		t.Fatalf("expected the torn line to be skipped, got %s", ids(logs))
+	}
+
+	if err := store.Append(logType, testLog("second", "shop")); err != nil {
+		t.Fatal(err)
+	}
+	if logs := queryAll(t, store, logType, LogQuery{}); ids(logs) != "first,second" {
+		t.Fatalf("expected the torn line to be dropped, got %s", ids(logs))
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "torn") || strings.Count(string(content), "\n") != 2 {
+		t.Fatalf("expected two complete lines, got %q", content)
+	}
+}
+
+func TestTornLineWithoutCompleteLine(t *testing.T) {
+	dir := useLogDir(t)
+	var logType LogType = "TornOnly"
+	writeFile(t, filepath.Join(dir, "TornOnly.jsonl"), `{"id":"torn"`)
+	store := &fileLogStore{}
+
+	if err := store.Append(logType, testLog("first", "shop")); err != nil {
+		t.Fatal(err)
+	}
+	if logs := queryAll(t, store, logType, LogQuery{}); ids(logs) != "first" {
+		t.Fatalf("expected only the appended log, got %s", ids(logs))
+	}
+}
+
+func TestUnreadableLinesAreSkipped(t *testing.T) {
+	dir := useLogDir(t)
+	var logType LogType = "Unreadable"
+	writeFile(t, filepath.Join(dir, "Unreadable.jsonl"),
+		`{"id":"first","success":true}` + "\n" + "garbage\n" + `{"id":"second","success":true}` + "\n")
+
+	if logs := queryAll(t, &fileLogStore{}, logType, LogQuery{}); ids(logs) != "first,second" {
+		t.Fatalf("expected the unreadable line to be skipped, got %s", ids(logs))
+	}
+}
+
+func TestConcurrentAppends(t *testing.T) {
+	useLogDir(t)
+	var logType LogType = "Concurrent"
+	store := &fileLogStore{}
+
+	var appended sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		appended.Add(1)
+		go func(i int) {
+			defer appended.Done()
+			if err := store.Append(logType, testLog(strings.Repeat("x", i + 1), "shop")); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	appended.Wait()
+
+	if logs := queryAll(t, store, logType, LogQuery{}); len(logs) != 50 {
+		t.Fatalf("expected 50 logs, got %d", len(logs))
+	}
+}
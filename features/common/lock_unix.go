@@ -0,0 +1,22 @@
+// +build !windows
+
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile waits for an exclusive, or shared, lock of the file, which other processes
+// locking it respect.
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(file.Fd()), how)
+}
+
+func unlockFile(file *os.File) {
+	_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
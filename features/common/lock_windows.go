@@ -0,0 +1,13 @@
+// +build windows
+
+package common
+
+import "os"
+
+// lockFile does not lock the file on Windows, where only the mutexes of Prolific
+// serialize its accesses.
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(file *os.File) {}
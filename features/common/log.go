@@ -2,18 +2,20 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
-	"prolific/debug"
+	"prolific/logger"
 )
 
-var GitHubLogType LogType = "GitHub"
 var logDirPath = filepath.Join("logs")
 
+// gitHubLogType is the log type of GitHub, whose logs had their API responses under
+// github_api_responses before logs of every provider had api_responses.
+const gitHubLogType LogType = "GitHub"
+
 type Log struct {
+	ID			string `json:"id,omitempty"`
 	Success		bool   `json:"success"`
+	Interrupted	bool   `json:"interrupted,omitempty"`
 	StartedAt	string   `json:"started_at"`
 	EndedAt		string `json:"ended_at"`
 	TimeElapsed	string `json:"time_elapsed"`
@@ -27,48 +29,48 @@ type LogData struct {
 	Owner				string                    `json:"owner"`
 	Repository			string                   `json:"repository"`
 	Branch				string                   `json:"branch"`
-	GitHubApiResponses	[]map[string]interface{} `json:"github_api_responses"`
+	Trigger				string                   `json:"trigger,omitempty"`
+	Ref					string                   `json:"ref,omitempty"`
+	CommitSha			string                   `json:"commit_sha,omitempty"`
+	Release				string                   `json:"release,omitempty"`
+	TriggeredBy			string                   `json:"triggered_by,omitempty"`
+	Reason				string                   `json:"reason,omitempty"`
+	RollbackOf			string                   `json:"rollback_of,omitempty"`
+	// GitHubApiResponses repeats ApiResponses in GitHub logs, for the readers of the key
+	// GitHub logs had before ApiResponses, which older GitHub logs only have.
+	GitHubApiResponses	[]map[string]interface{} `json:"github_api_responses,omitempty"`
+	ApiResponses		[]map[string]interface{} `json:"api_responses"`
 	ExecutableLogs		[]ExecutableLog          `json:"executable_logs"`
 }
 
 type LogType string
 
+// ReadLogs returns the logs of logType, oldest first.
 func ReadLogs(logType LogType) Logs {
-	logFilePath := filepath.Join(logDirPath, fmt.Sprintf("%s.json", logType))
-	if _, err := os.Stat(logDirPath); os.IsNotExist(err) {
-		err = os.MkdirAll(logDirPath, os.ModePerm)
-		if err != nil {
-			debug.Println(err.Error())
-		}
-	}
-	if _, err := os.Stat(logFilePath); err != nil {
-		debug.Println(err.Error())
-		return Logs{}
+	return QueryLogs(logType, LogQuery{})
+}
+
+// WriteLog records the log in the log store.
+func WriteLog(logType LogType, log Log) {
+	if logType == gitHubLogType && log.Data != nil && log.Data.GitHubApiResponses == nil {
+		data := *log.Data
+		data.GitHubApiResponses = data.ApiResponses
+		log.Data = &data
 	}
-	content, err := ioutil.ReadFile(logFilePath)
-	if err != nil {
-		debug.Println(err.Error())
-		return Logs{}
+	if log.ID == "" {
+		content, _ := json.Marshal(log)
+		log.ensureID(content)
 	}
-	var logs Logs
-	err = json.Unmarshal(content, &logs)
-	if err != nil {
-		debug.Println(err.Error())
-		return Logs{}
+	if err := logStore.Append(logType, log); err != nil {
+		logger.Error("Log could not be written", "log_type", logType, "deployment", log.ID, "error", err)
 	}
-	return logs
 }
 
-func WriteLog(logType LogType, log Log) {
-	logFilePath := filepath.Join(logDirPath, fmt.Sprintf("%s.json", logType))
-	logs := ReadLogs(logType)
-	logs = append(logs, log)
-	content, err := json.MarshalIndent(logs, "", "\t")
-	if err != nil {
-		debug.Println(err.Error())
-	}
-	err = ioutil.WriteFile(logFilePath, content, 0755)
+// FindLog returns the log with the given ID, or nil when there is none.
+func FindLog(logType LogType, id string) *Log {
+	log, err := logStore.Find(logType, id)
 	if err != nil {
-		debug.Println(err.Error())
+		logger.Error("Log could not be read", "log_type", logType, "deployment", id, "error", err)
 	}
+	return log
 }
\ No newline at end of file
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"prolific/config"
+	"prolific/logger"
+	"time"
+)
+
+// LogStore keeps the logs of the deployments of every provider, each provider's under
+// its own LogType.
+type LogStore interface {
+	// Append records the log of a finished deployment.
+	Append(logType LogType, log Log) error
+	// Query passes the logs matching the query to visit, in the order of the query,
+	// until visit returns false.
+	Query(logType LogType, query LogQuery, visit func(log *Log) bool) error
+	// Find returns the log with the given ID, or nil when there is none.
+	Find(logType LogType, id string) (*Log, error)
+	// Remove removes the logs whose IDs are given, and returns how many were removed.
+	Remove(logType LogType, ids map[string]bool) (int, error)
+	// LogTypes returns the log types the store holds logs of.
+	LogTypes() ([]LogType, error)
+	Close() error
+}
+
+// LogQuery selects logs by owner, repository, branch, status and start time. Zero
+// fields select every log. Logs are selected oldest first, or newest first when
+// Descending, starting after the log with the ID After when given.
+type LogQuery struct {
+	Owner		string
+	Repository	string
+	Branch		string
+	Success		*bool
+	Since		time.Time
+	Until		time.Time
+	After		string
+	Descending	bool
+}
+
+const (
+	FileLogStore	= "file"
+	BoltLogStore	= "bolt"
+)
+
+var logStore LogStore = &fileLogStore{}
+
+// OpenLogStore opens the store configured by PROLIFIC_LOG_STORE, the journal files by
+// default, or the bolt database at PROLIFIC_LOG_DATABASE.
+func OpenLogStore() error {
+	switch kind := config.GetWithDefault("Prolific", "Log_Store", FileLogStore); kind {
+	case FileLogStore:
+		logStore = &fileLogStore{}
+	case BoltLogStore:
+		store, err := openBoltLogStore(config.GetWithDefault("Prolific", "Log_Database", "logs/prolific.db"))
+		if err != nil {
+			return err
+		}
+		logStore = store
+	default:
+		return errors.New("unknown log store " + kind)
+	}
+	logger.Info("Deployment log store opened", "store", config.GetWithDefault("Prolific", "Log_Store", FileLogStore))
+	return nil
+}
+
+// CloseLogStore closes the store opened by OpenLogStore.
+func CloseLogStore() {
+	if err := logStore.Close(); err != nil {
+		logger.Error("Log store could not be closed", "error", err)
+	}
+}
+
+// QueryLogs returns the logs of logType matching the query.
+func QueryLogs(logType LogType, query LogQuery) Logs {
+	logs, _ := QueryLogPage(logType, query, 0)
+	return logs
+}
+
+// QueryLogPage returns at most limit logs of logType matching the query, or all of
+// them when limit is 0, and the ID of the last one when more logs follow, with which
+// the next page is queried.
+func QueryLogPage(logType LogType, query LogQuery, limit int) (logs Logs, next string) {
+	logs = Logs{}
+	more := false
+	err := logStore.Query(logType, query, func(log *Log) bool {
+		if limit > 0 && len(logs) == limit {
+			more = true
+			return false
+		}
+		logs = append(logs, *log)
+		return true
+	})
+	if err != nil {
+		logger.Error("Logs could not be queried", "log_type", logType, "error", err)
+	}
+	if more {
+		next = logs[len(logs)-1].ID
+	}
+	return logs, next
+}
+
+// matches reports whether the log is selected by the query.
+func (query LogQuery) matches(log *Log) bool {
+	if query.Success != nil && log.Success != *query.Success {
+		return false
+	}
+	if !query.Since.IsZero() || !query.Until.IsZero() {
+		startedAt, err := log.startTime()
+		if err != nil || (!query.Since.IsZero() && startedAt.Before(query.Since)) ||
+			(!query.Until.IsZero() && !startedAt.Before(query.Until)) {
+			return false
+		}
+	}
+	if query.Owner == "" && query.Repository == "" && query.Branch == "" {
+		return true
+	}
+	return log.Data != nil &&
+		(query.Owner == "" || log.Data.Owner == query.Owner) &&
+		(query.Repository == "" || log.Data.Repository == query.Repository) &&
+		(query.Branch == "" || log.Data.Branch == query.Branch)
+}
+
+// ensureID gives a log without an ID one derived from line, the JSON it is stored as.
+// Logs are stored along with their ID, except those journaled before logs had IDs,
+// whose lines are never rewritten, so that the ID of a log never changes.
+func (log *Log) ensureID(line []byte) {
+	if log.ID != "" {
+		return
+	}
+	sum := sha256.Sum256(bytes.TrimSpace(line))
+	log.ID = hex.EncodeToString(sum[:8])
+}
+
+// marshalWithID returns the JSON of the log to store, giving the log an ID derived from
+// its JSON first when it has none.
+func (log *Log) marshalWithID() ([]byte, error) {
+	content, err := json.Marshal(log)
+	if err != nil || log.ID != "" {
+		return content, err
+	}
+	log.ensureID(content)
+	return json.Marshal(log)
+}
+
+func (log *Log) startTime() (time.Time, error) {
+	return time.Parse(time.RFC1123, log.StartedAt)
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useLogDir points the logs to a temporary directory, and returns it.
+func useLogDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := logDirPath
+	logDirPath = dir
+	t.Cleanup(func() {
+		logDirPath = previous
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const legacyLine = `{"success":true,"started_at":"Mon, 02 Jan 2006 15:04:05 UTC","ended_at":"","time_elapsed":"","data":{"owner":"acme","repository":"shop","branch":"main","api_responses":null,"executable_logs":null}}`
+
+func TestLegacyIDsAreStable(t *testing.T) {
+	dir := useLogDir(t)
+	var logType LogType = "LegacyIDs"
+	writeFile(t, filepath.Join(dir, "LegacyIDs.jsonl"), legacyLine + "\n")
+	store := &fileLogStore{}
+
+	logs := queryAll(t, store, logType, LogQuery{})
+	if len(logs) != 1 || logs[0].ID == "" {
+		t.Fatalf("expected a legacy log with an ID, got %+v", logs)
+	}
+	id := logs[0].ID
+
+	// The ID is derived from the stored line rather than from how Log is marshalled,
+	// which fields added to Log would change.
+	var stored Log
+	stored.ensureID([]byte(legacyLine))
+	if stored.ID != id {
+		t.Fatalf("expected ID %s derived from the stored line, got %s", stored.ID, id)
+	}
+	if found, _ := store.Find(logType, id); found == nil {
+		t.Fatalf("expected log %s to be found", id)
+	}
+
+	boltStore, err := openBoltLogStore(filepath.Join(dir, "prolific.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boltStore.Close()
+	imported := queryAll(t, boltStore, logType, LogQuery{})
+	if len(imported) != 1 || imported[0].ID != id {
+		t.Fatalf("expected the imported log to keep ID %s, got %+v", id, imported)
+	}
+	if found, err := boltStore.Find(logType, id); err != nil || found == nil {
+		t.Fatalf("expected log %s to be found in the database, got %v", id, err)
+	}
+}
+
+func TestMigrationStoresIDs(t *testing.T) {
+	dir := useLogDir(t)
+	var logType LogType = "MigratedIDs"
+	writeFile(t, filepath.Join(dir, "MigratedIDs.json"), "[" + legacyLine + "]")
+
+	logs := queryAll(t, &fileLogStore{}, logType, LogQuery{})
+	if len(logs) != 1 || logs[0].ID == "" {
+		t.Fatalf("expected the migrated log with an ID, got %+v", logs)
+	}
+	journal, err := ioutil.ReadFile(filepath.Join(dir, "MigratedIDs.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(journal), `"id":"` + logs[0].ID + `"`) {
+		t.Fatalf("expected the ID to be stored in the journal, got %s", journal)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "MigratedIDs.json.migrated")); err != nil {
+		t.Fatalf("expected the legacy logs to be renamed, got %v", err)
+	}
+}
+
+func queryAll(t *testing.T, store LogStore, logType LogType, query LogQuery) Logs {
+	var logs Logs
+	err := store.Query(logType, query, func(log *Log) bool {
+		logs = append(logs, *log)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+func TestGitHubLogsKeepTheirApiResponsesKey(t *testing.T) {
+	dir := useLogDir(t)
+	previous := logStore
+	logStore = &fileLogStore{}
+	defer func() { logStore = previous }()
+
+	responses := []map[string]interface{}{ { "id": "1" } }
+	for _, logType := range []LogType{ gitHubLogType, "GitLab" } {
+		WriteLog(logType, Log{ ID: "d1", Data: &LogData{ ApiResponses: responses } })
+	}
+	gitHub, err := ioutil.ReadFile(filepath.Join(dir, "GitHub.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(gitHub), `"github_api_responses":[{"id":"1"}]`) ||
+		!strings.Contains(string(gitHub), `"api_responses":[{"id":"1"}]`) {
+		t.Errorf("expected GitHub logs to have both keys, got %s", gitHub)
+	}
+	gitLab, err := ioutil.ReadFile(filepath.Join(dir, "GitLab.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(gitLab), "github_api_responses") {
+		t.Errorf("expected other logs to only have api_responses, got %s", gitLab)
+	}
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// maxLineLength is the length beyond which a line without an end is passed on anyway.
+const maxLineLength = 64 * 1024
+
+// outputBuffer keeps the last bytes written to it, up to its limit when it has one. It
+// is safe for concurrent use, as stdout and stderr are copied concurrently.
+type outputBuffer struct {
+	mutex	sync.Mutex
+	limit	int
+	data	[]byte
+	written	int64
+}
+
+func newOutputBuffer(limit int) *outputBuffer {
+	return &outputBuffer{ limit: limit }
+}
+
+func (buffer *outputBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	buffer.written += int64(len(p))
+	buffer.data = append(buffer.data, p...)
+	// Dropping the bytes beyond the limit only once they double it avoids moving the
+	// kept bytes on every write.
+	if buffer.limit > 0 && len(buffer.data) > 2 * buffer.limit {
+		buffer.data = append(buffer.data[:0], buffer.data[len(buffer.data) - buffer.limit:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the bytes kept, preceded by a note of how many were dropped.
+func (buffer *outputBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	if buffer.limit > 0 && buffer.written > int64(buffer.limit) {
+		return fmt.Sprintf("[%d bytes truncated]\n%s",
+			buffer.written - int64(buffer.limit), buffer.data[len(buffer.data) - buffer.limit:])
+	}
+	return string(buffer.data)
+}
+
+func (buffer *outputBuffer) truncated() bool {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.limit > 0 && buffer.written > int64(buffer.limit)
+}
+
+// outputWriter writes an output stream both to its own buffer and to the buffer
+// combining every stream, and passes each of its lines to onLine when given.
+type outputWriter struct {
+	stream		*outputBuffer
+	combined	*outputBuffer
+	name		string
+	onLine		func(stream string, line string)
+	partial		[]byte
+}
+
+func (writer *outputWriter) Write(p []byte) (int, error) {
+	writer.stream.Write(p)
+	if writer.onLine != nil {
+		writer.partial = append(writer.partial, p...)
+		for {
+			end := bytes.IndexByte(writer.partial, '\n')
+			if end < 0 {
+				break
+			}
+			writer.onLine(writer.name, string(writer.partial[:end]))
+			writer.partial = writer.partial[end+1:]
+		}
+		if len(writer.partial) > maxLineLength {
+			writer.flush()
+		}
+	}
+	return writer.combined.Write(p)
+}
+
+// flush passes on the last line written, when it has no end.
+func (writer *outputWriter) flush() {
+	if writer.onLine != nil && len(writer.partial) > 0 {
+		writer.onLine(writer.name, string(writer.partial))
+	}
+	writer.partial = nil
+}
@@ -0,0 +1,58 @@
+// +build !windows
+
+package common
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// configureProcess makes the command the leader of a new process group, so that the
+// processes it spawns can be killed along with it, and runs it as the account when it
+// is not the account of Prolific itself.
+func configureProcess(command *exec.Cmd, account *user.User) error {
+	command.SysProcAttr = &syscall.SysProcAttr{ Setpgid: true }
+	if account == nil {
+		return nil
+	}
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+	if uint64(os.Geteuid()) == uid && uint64(os.Getegid()) == gid {
+		return nil
+	}
+	var groups []uint32
+	groupIds, err := account.GroupIds()
+	if err != nil {
+		return err
+	}
+	for _, groupId := range groupIds {
+		group, err := strconv.ParseUint(groupId, 10, 32)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, uint32(group))
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}
+	return nil
+}
+
+// killProcessGroup kills the command and every process of its group.
+func killProcessGroup(command *exec.Cmd) {
+	if command.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,26 @@
+// +build windows
+
+package common
+
+import (
+	"errors"
+	"os/exec"
+	"os/user"
+)
+
+// configureProcess refuses to run commands as another account, which is not supported
+// on Windows.
+func configureProcess(command *exec.Cmd, account *user.User) error {
+	if account != nil {
+		return errors.New("running as user " + account.Username + " is not supported on Windows")
+	}
+	return nil
+}
+
+// killProcessGroup kills the command only, as Windows has no process groups to kill.
+func killProcessGroup(command *exec.Cmd) {
+	if command.Process == nil {
+		return
+	}
+	_ = command.Process.Kill()
+}
@@ -0,0 +1,241 @@
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"prolific/config"
+	"prolific/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy tells which deployment logs are kept: the last KeepPerRepository
+// deployments of each repository, and those younger than MaxAge. Zero fields keep every
+// log. Archived access logs older than MaxAge are removed as well.
+type RetentionPolicy struct {
+	KeepPerRepository	int
+	MaxAge				time.Duration
+}
+
+// CompactionReport is what a compaction removed and archived.
+type CompactionReport struct {
+	KeepPerRepository	int				`json:"keep_per_repository"`
+	MaxAge				string			`json:"max_age,omitempty"`
+	RemovedLogs			map[LogType]int	`json:"removed_logs"`
+	ArchivedAccessLogs	[]string		`json:"archived_access_logs,omitempty"`
+	RemovedAccessLogs	[]string		`json:"removed_access_logs,omitempty"`
+}
+
+var (
+	compactionMutex		sync.Mutex
+	stopCompactor		= make(chan struct{})
+	compactorStopped	= make(chan struct{})
+)
+
+// ConfiguredRetentionPolicy reads the retention policy from PROLIFIC_LOG_KEEP_PER_REPOSITORY
+// and PROLIFIC_LOG_MAX_AGE, a duration such as "720h" or a number of days such as "30d".
+func ConfiguredRetentionPolicy() RetentionPolicy {
+	var policy RetentionPolicy
+	keep, err := strconv.Atoi(config.GetWithDefault("Prolific", "Log_Keep_Per_Repository", "0"))
+	if err != nil || keep < 0 {
+		logger.Warn("Invalid PROLIFIC_LOG_KEEP_PER_REPOSITORY ignored", "value", config.Get("Prolific", "Log_Keep_Per_Repository"))
+	} else {
+		policy.KeepPerRepository = keep
+	}
+	if value := config.Get("Prolific", "Log_Max_Age"); value != "" {
+		maxAge, err := parseAge(value)
+		if err != nil || maxAge < 0 {
+			logger.Warn("Invalid PROLIFIC_LOG_MAX_AGE ignored", "value", value)
+		} else {
+			policy.MaxAge = maxAge
+		}
+	}
+	return policy
+}
+
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// StartCompactor compacts the logs every interval, as configured by
+// PROLIFIC_COMPACTION_INTERVAL, 24h by default, unless the interval is 0.
+func StartCompactor() {
+	interval, err := time.ParseDuration(config.GetWithDefault("Prolific", "Compaction_Interval", "24h"))
+	if err != nil || interval <= 0 {
+		close(compactorStopped)
+		logger.Info("Log compaction disabled")
+		return
+	}
+	go func() {
+		defer close(compactorStopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCompactor:
+				return
+			case <-ticker.C:
+				if _, err := Compact(ConfiguredRetentionPolicy()); err != nil {
+					logger.Error("Log compaction failed", "error", err)
+				}
+			}
+		}
+	}()
+	logger.Info("Log compaction scheduled", "interval", interval.String())
+}
+
+// StopCompactor stops the compactions started by StartCompactor, and waits for any
+// compaction under way, scheduled or not, so that the log store can then be closed.
+func StopCompactor() {
+	close(stopCompactor)
+	<-compactorStopped
+	compactionMutex.Lock()
+	compactionMutex.Unlock()
+	logger.Info("Log compaction stopped")
+}
+
+// Compact removes the deployment logs the policy does not keep, gzips the access logs of
+// previous runs, and removes the archived access logs older than the policy's MaxAge.
+func Compact(policy RetentionPolicy) (CompactionReport, error) {
+	compactionMutex.Lock()
+	defer compactionMutex.Unlock()
+
+	report := CompactionReport{ KeepPerRepository: policy.KeepPerRepository, RemovedLogs: map[LogType]int{} }
+	if policy.MaxAge > 0 {
+		report.MaxAge = policy.MaxAge.String()
+	}
+	logTypes, err := logStore.LogTypes()
+	if err != nil {
+		return report, err
+	}
+	for _, logType := range logTypes {
+		expired, err := expiredLogs(logType, policy)
+		if err != nil {
+			return report, err
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		removed, err := logStore.Remove(logType, expired)
+		if err != nil {
+			return report, err
+		}
+		report.RemovedLogs[logType] = removed
+		logger.Info("Logs removed by compaction", "log_type", logType, "logs", removed)
+	}
+
+	report.ArchivedAccessLogs, report.RemovedAccessLogs, err = compactAccessLogs(policy.MaxAge)
+	return report, err
+}
+
+// expiredLogs returns the IDs of the logs of logType the policy does not keep.
+func expiredLogs(logType LogType, policy RetentionPolicy) (map[string]bool, error) {
+	expired := map[string]bool{}
+	if policy.KeepPerRepository == 0 && policy.MaxAge == 0 {
+		return expired, nil
+	}
+	cutoff := time.Now().Add(-policy.MaxAge)
+	kept := map[string]int{}
+	err := logStore.Query(logType, LogQuery{ Descending: true }, func(log *Log) bool {
+		repository := ""
+		if log.Data != nil {
+			repository = log.Data.Owner + "/" + log.Data.Repository
+		}
+		kept[repository]++
+		if policy.KeepPerRepository > 0 && kept[repository] > policy.KeepPerRepository {
+			expired[log.ID] = true
+		} else if startedAt, err := log.startTime(); policy.MaxAge > 0 && err == nil && startedAt.Before(cutoff) {
+			expired[log.ID] = true
+		}
+		return true
+	})
+	return expired, err
+}
+
+// compactAccessLogs gzips the access logs written before the logger replaced them, and
+// removes the archives older than maxAge, unless it is 0.
+func compactAccessLogs(maxAge time.Duration) (archived []string, removed []string, err error) {
+	paths, err := filepath.Glob(filepath.Join(logDirPath, "access-log-*.log"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range paths {
+		if err := gzipFile(path); err != nil {
+			return archived, removed, err
+		}
+		archived = append(archived, filepath.Base(path) + ".gz")
+	}
+
+	if maxAge == 0 {
+		return archived, removed, nil
+	}
+	archives, err := filepath.Glob(filepath.Join(logDirPath, "access-log-*.log.gz"))
+	if err != nil {
+		return archived, removed, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, path := range archives {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return archived, removed, err
+		}
+		removed = append(removed, filepath.Base(path))
+	}
+	return archived, removed, nil
+}
+
+// gzipFile replaces the file with its gzipped copy, named after it with ".gz" appended,
+// which keeps its modification time.
+func gzipFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	temporaryPath := path + ".gz.tmp"
+	archive, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(archive)
+	_, err = io.Copy(writer, source)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = archive.Sync()
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(temporaryPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(temporaryPath, path + ".gz")
+	}
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+	return os.Remove(path)
+}
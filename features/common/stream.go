@@ -0,0 +1,265 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+const (
+	StreamStepEvent		= "step"
+	StreamOutputEvent	= "output"
+	StreamStepEndEvent	= "step_end"
+	StreamEndEvent		= "end"
+
+	// streamHistoryLimit is the number of events of a stream kept for late subscribers.
+	streamHistoryLimit	= 10000
+	// streamBacklogLimit is the number of events a subscriber may lag behind before it
+	// is dropped, and has to subscribe again.
+	streamBacklogLimit	= 1024
+)
+
+// StreamEvent is an event of the stream of a deployment. ID orders the events of a
+// stream, starting from 1.
+type StreamEvent struct {
+	ID		int
+	Type	string
+	Data	interface{}
+}
+
+// StreamStep announces a step, or a health check, of a deployment.
+type StreamStep struct {
+	Step	string	`json:"step"`
+	Args	string	`json:"args,omitempty"`
+}
+
+// StreamOutput is a line written by a step to its stdout or stderr.
+type StreamOutput struct {
+	Step	string	`json:"step"`
+	Stream	string	`json:"stream"`
+	Line	string	`json:"line"`
+}
+
+// StreamStepEnd is the outcome of a step.
+type StreamStepEnd struct {
+	Step		string	`json:"step"`
+	ExitCode	*int	`json:"exit_code,omitempty"`
+	TimeElapsed	string	`json:"time_elapsed,omitempty"`
+	Error		string	`json:"error,omitempty"`
+}
+
+// StreamEnd is the outcome of a deployment, and the last event of its stream.
+type StreamEnd struct {
+	Success		bool	`json:"success"`
+	Interrupted	bool	`json:"interrupted,omitempty"`
+	Error		string	`json:"error,omitempty"`
+}
+
+// Stream publishes the progress of a running deployment to its subscribers, and keeps
+// its events so that subscribers joining late are replayed what already happened. All
+// of its methods may be called on a nil stream, which publishes nothing.
+type Stream struct {
+	mutex		sync.Mutex
+	key			string
+	events		[]StreamEvent
+	lastID		int
+	subscribers	map[chan StreamEvent]bool
+	closed		bool
+}
+
+type streamContextKey struct{}
+
+var (
+	streamsMutex	sync.Mutex
+	streams			= map[string]*Stream{}
+)
+
+func streamKey(logType LogType, id string) string {
+	return string(logType) + "/" + id
+}
+
+// OpenStream opens the stream of the deployment with the given ID, which is the ID of
+// its log.
+func OpenStream(logType LogType, id string) *Stream {
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+	key := streamKey(logType, id)
+	stream := &Stream{ key: key, subscribers: map[chan StreamEvent]bool{} }
+	streams[key] = stream
+	return stream
+}
+
+// FindStream returns the stream of the running deployment with the given ID, or nil
+// when it is not running.
+func FindStream(logType LogType, id string) *Stream {
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+	return streams[streamKey(logType, id)]
+}
+
+// NewStreamContext returns a copy of ctx carrying the stream, to which the executables
+// run with it publish their output.
+func NewStreamContext(ctx context.Context, stream *Stream) context.Context {
+	return context.WithValue(ctx, streamContextKey{}, stream)
+}
+
+// StreamFromContext returns the stream carried by ctx, or nil when there is none.
+func StreamFromContext(ctx context.Context) *Stream {
+	stream, _ := ctx.Value(streamContextKey{}).(*Stream)
+	return stream
+}
+
+// Publish sends an event to the subscribers of the stream, and returns its ID, which is 0
+// when nothing was published. Subscribers lagging too far behind are dropped rather than
+// slowing the deployment down.
+func (stream *Stream) Publish(eventType string, data interface{}) int {
+	if stream == nil {
+		return 0
+	}
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.closed {
+		return 0
+	}
+	return stream.publish(eventType, data)
+}
+
+// publish sends an event to the subscribers. The caller must hold the mutex.
+func (stream *Stream) publish(eventType string, data interface{}) int {
+	stream.lastID++
+	event := StreamEvent{ ID: stream.lastID, Type: eventType, Data: data }
+	stream.events = append(stream.events, event)
+	if len(stream.events) > streamHistoryLimit {
+		stream.events = append(stream.events[:0], stream.events[len(stream.events) - streamHistoryLimit:]...)
+	}
+	for subscriber := range stream.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(stream.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return event.ID
+}
+
+// Close publishes the end of the deployment, ends every subscription and forgets the
+// stream, whose deployment is from then on replayed from its log.
+func (stream *Stream) Close(end StreamEnd) {
+	if stream == nil {
+		return
+	}
+	streamsMutex.Lock()
+	if streams[stream.key] == stream {
+		delete(streams, stream.key)
+	}
+	streamsMutex.Unlock()
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.closed {
+		return
+	}
+	stream.publish(StreamEndEvent, end)
+	stream.closed = true
+	for subscriber := range stream.subscribers {
+		delete(stream.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Subscribe returns the events kept with an ID greater than after, and the channel of
+// the events that follow, which is closed once the stream is closed or the subscriber
+// is dropped. Unsubscribe must be called once the subscriber stops reading.
+func (stream *Stream) Subscribe(after int) ([]StreamEvent, chan StreamEvent) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	var history []StreamEvent
+	for _, event := range stream.events {
+		if event.ID > after {
+			history = append(history, event)
+		}
+	}
+	subscriber := make(chan StreamEvent, streamBacklogLimit)
+	if stream.closed {
+		close(subscriber)
+	} else {
+		stream.subscribers[subscriber] = true
+	}
+	return history, subscriber
+}
+
+func (stream *Stream) Unsubscribe(subscriber chan StreamEvent) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.subscribers[subscriber] {
+		delete(stream.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// LogStreamEvents returns the events a stream would have published for the finished
+// deployment of the log. Steps keep the IDs their step and step_end events were
+// published with, so that subscribers resume where they stopped whether the deployment
+// was streamed or is replayed. As the log does not interleave stdout and stderr, and
+// keeps the last lines of outputs that exceeded the limit, the lines of stdout come
+// before those of stderr, numbered so that the last one precedes the step_end event.
+// Steps logged without IDs, as those of deployments logged before, are numbered after
+// the previous event.
+func LogStreamEvents(log *Log) []StreamEvent {
+	var events []StreamEvent
+	lastID := 0
+	add := func(id int, eventType string, data interface{}) {
+		if id <= 0 {
+			id = lastID + 1
+		}
+		lastID = id
+		events = append(events, StreamEvent{ ID: id, Type: eventType, Data: data })
+	}
+	if log.Data != nil {
+		for _, executableLog := range log.Data.ExecutableLogs {
+			step := executableLog.Step
+			if step == "" {
+				step = executableLog.Name
+			}
+			add(executableLog.FirstEventID, StreamStepEvent, StreamStep{ Step: step, Args: executableLog.Args })
+			outputs := map[string]string{ "stdout": executableLog.Stdout, "stderr": executableLog.Stderr }
+			if executableLog.Stdout == "" && executableLog.Stderr == "" {
+				// Logs recorded before outputs were separated only have the combined output.
+				outputs = map[string]string{ "stdout": executableLog.Output }
+			}
+			var lines []StreamOutput
+			for _, name := range []string{ "stdout", "stderr" } {
+				for _, line := range outputLines(outputs[name]) {
+					lines = append(lines, StreamOutput{ Step: step, Stream: name, Line: line })
+				}
+			}
+			firstID := lastID
+			for index, line := range lines {
+				id := 0
+				if executableLog.LastEventID > 0 {
+					id = executableLog.LastEventID - len(lines) + index
+					if id < firstID {
+						id = firstID
+					}
+				}
+				add(id, StreamOutputEvent, line)
+			}
+			add(executableLog.LastEventID, StreamStepEndEvent, StreamStepEnd{
+				Step:        step,
+				ExitCode:    executableLog.ExitCode,
+				TimeElapsed: executableLog.TimeElapsed,
+				Error:       executableLog.Error,
+			})
+		}
+	}
+	add(0, StreamEndEvent, StreamEnd{ Success: log.Success, Interrupted: log.Interrupted, Error: log.Error })
+	return events
+}
+
+func outputLines(output string) []string {
+	if output == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
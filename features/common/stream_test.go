@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReplayedEventsKeepTheStreamedIDs(t *testing.T) {
+	stream := OpenStream("Replay", "d1")
+	history, events := stream.Subscribe(0)
+	defer stream.Unsubscribe(events)
+
+	var executableLogs []ExecutableLog
+	for _, step := range []string{ "build", "deploy" } {
+		executableLog := ExecutableLog{ Step: step, Stdout: "one\ntwo\n", Stderr: "warning\n" }
+		executableLog.FirstEventID = stream.Publish(StreamStepEvent, StreamStep{ Step: step })
+		for _, line := range []string{ "one", "warning", "two" } {
+			stream.Publish(StreamOutputEvent, StreamOutput{ Step: step, Line: line })
+		}
+		executableLog.LastEventID = stream.Publish(StreamStepEndEvent, StreamStepEnd{ Step: step })
+		executableLogs = append(executableLogs, executableLog)
+	}
+	log := &Log{ Success: true, Data: &LogData{ ExecutableLogs: executableLogs } }
+	stream.Close(StreamEnd{ Success: true })
+
+	for event := range events {
+		history = append(history, event)
+	}
+	var streamed, replayed []string
+	for _, event := range history {
+		streamed = append(streamed, fmt.Sprintf("%d:%s", event.ID, event.Type))
+	}
+	// Lines are replayed in another order, but within the IDs of their step.
+	for _, event := range LogStreamEvents(log) {
+		replayed = append(replayed, fmt.Sprintf("%d:%s", event.ID, event.Type))
+	}
+	if fmt.Sprint(replayed) != fmt.Sprint(streamed) {
+		t.Fatalf("expected the replayed events %v to keep the streamed IDs %v", replayed, streamed)
+	}
+}
+
+func TestReplayedEventsOfLegacyLogs(t *testing.T) {
+	log := &Log{ Data: &LogData{ ExecutableLogs: []ExecutableLog{ { Name: "make", Output: "a\nb\n" } } } }
+	for index, event := range LogStreamEvents(log) {
+		if event.ID != index + 1 {
+			t.Fatalf("expected event %d to have ID %d, got %d", index, index + 1, event.ID)
+		}
+	}
+}
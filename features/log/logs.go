@@ -0,0 +1,123 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/url"
+	"prolific/features/common"
+	"prolific/provider"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize	= 100
+	maxPageSize		= 1000
+)
+
+// listLogs returns the handler listing the deployment logs of a provider, authorized with
+// the provider's log access token. Logs are filtered by the parameters of the query, and
+// listed oldest first as before logs were paginated, unless the query sorts or pages them,
+// newest first and by pages of 100 by default.
+func listLogs(p provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		if !common.Authorize(writer, request, p.Name(), "Log_Access_Token") {
+			return
+		}
+
+		response := common.CreateResponse()
+
+		query, limit, err := readLogQuery(request.URL.Query())
+		if err != nil {
+			statusCode := http.StatusBadRequest
+			response.SetError(common.CreateError(statusCode, err.Error()))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		response.Data, response.NextCursor = common.QueryLogPage(p.LogType(), query, limit)
+		common.SendResponse(writer, response)
+
+	}
+}
+
+// readLogQuery reads the filters, sort order and page of a log listing from the
+// parameters owner, repository, branch, success, since and until, sort, asc or desc,
+// limit and cursor. Without any of sort, limit and cursor, every log is listed oldest
+// first, with a limit of 0.
+func readLogQuery(values url.Values) (common.LogQuery, int, error) {
+	query := common.LogQuery{
+		Owner:      values.Get("owner"),
+		Repository: values.Get("repository"),
+		Branch:     values.Get("branch"),
+		After:      values.Get("cursor"),
+		Descending: true,
+	}
+
+	if success := values.Get("success"); success != "" {
+		value, err := strconv.ParseBool(success)
+		if err != nil {
+			return query, 0, errors.New("Invalid success, expected true or false.")
+		}
+		query.Success = &value
+	}
+	for name, field := range map[string]*time.Time{ "since": &query.Since, "until": &query.Until } {
+		if value := values.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, 0, fmt.Errorf("Invalid %s, expected an RFC 3339 time.", name)
+			}
+			*field = parsed
+		}
+	}
+	switch values.Get("sort") {
+	case "", "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		return query, 0, errors.New("Invalid sort, expected asc or desc.")
+	}
+
+	if values.Get("sort") == "" && values.Get("limit") == "" && values.Get("cursor") == "" {
+		query.Descending = false
+		return query, 0, nil
+	}
+
+	limit := defaultPageSize
+	if value := values.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return query, 0, fmt.Errorf("Invalid limit, expected a number from 1 to %d.", maxPageSize)
+		}
+		limit = parsed
+	}
+	return query, limit, nil
+}
+
+// showLog returns the handler of the log of a single deployment of a provider.
+func showLog(p provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		if !common.Authorize(writer, request, p.Name(), "Log_Access_Token") {
+			return
+		}
+
+		response := common.CreateResponse()
+
+		id := mux.Vars(request)["id"]
+		log := common.FindLog(p.LogType(), id)
+		if log == nil {
+			statusCode := http.StatusNotFound
+			response.SetError(common.CreateError(statusCode, "Log " + id + " not found."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		response.Data = log
+		common.SendResponse(writer, response)
+
+	}
+}
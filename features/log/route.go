@@ -3,14 +3,21 @@ package log
 import (
 	"github.com/gorilla/mux"
 	"net/http"
+	"prolific/provider"
 )
 
-func New() Route {
-	return Route{}
+func New(providers ...provider.Provider) Route {
+	return Route{ providers }
 }
 
-type Route struct {}
+type Route struct {
+	providers	[]provider.Provider
+}
 
 func (route Route) Initialise(r *mux.Router) {
-	r.Path("/github").Methods(http.MethodGet).HandlerFunc(github)
-}
\ No newline at end of file
+	for _, p := range route.providers {
+		r.Path("/" + p.Name()).Methods(http.MethodGet).HandlerFunc(listLogs(p))
+		r.Path("/" + p.Name() + "/{id}").Methods(http.MethodGet).HandlerFunc(showLog(p))
+		r.Path("/" + p.Name() + "/{deploymentId}/stream").Methods(http.MethodGet).HandlerFunc(streamLog(p))
+	}
+}
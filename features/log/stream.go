@@ -0,0 +1,191 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"prolific/features/common"
+	"prolific/logger"
+	"prolific/provider"
+	"prolific/queue"
+	"strconv"
+	"time"
+)
+
+const (
+	keepAliveInterval	= 15 * time.Second
+	queuedPollInterval	= time.Second
+	// eventWriteTimeout bounds the write of each event, so that a subscriber which stops
+	// reading is dropped instead of blocking its stream.
+	eventWriteTimeout	= 15 * time.Second
+)
+
+// eventStream writes Server-Sent Events to a connection taken over from the server, so
+// that the write timeout of the server does not end streams of long deployments.
+type eventStream struct {
+	connection	net.Conn
+	writer		*bufio.ReadWriter
+}
+
+// openEventStream takes the connection of the request over and answers it with the
+// headers of an event stream.
+func openEventStream(writer http.ResponseWriter) (*eventStream, error) {
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("connection cannot be taken over")
+	}
+	connection, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	_ = connection.SetDeadline(time.Time{})
+	eventStream := &eventStream{ connection: connection, writer: readWriter }
+	_, err = readWriter.WriteString("HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/event-stream\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Connection: close\r\n\r\n")
+	if err == nil {
+		err = readWriter.Flush()
+	}
+	if err != nil {
+		eventStream.close()
+		return nil, err
+	}
+	return eventStream, nil
+}
+
+func (stream *eventStream) send(event common.StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if err := stream.connection.SetWriteDeadline(time.Now().Add(eventWriteTimeout)); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stream.writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	if err != nil {
+		return err
+	}
+	return stream.writer.Flush()
+}
+
+// keepAlive sends a comment, which keeps proxies from closing an idle stream and tells
+// whether the subscriber is still connected.
+func (stream *eventStream) keepAlive() error {
+	if err := stream.connection.SetWriteDeadline(time.Now().Add(eventWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := stream.writer.WriteString(": keep-alive\n\n")
+	if err != nil {
+		return err
+	}
+	return stream.writer.Flush()
+}
+
+func (stream *eventStream) close() {
+	_ = stream.connection.Close()
+}
+
+// streamLog returns the handler streaming the steps and output lines of a deployment of
+// a provider as Server-Sent Events, authorized with the provider's log access token.
+// Subscribers are first replayed the events that already happened, after the one of
+// the Last-Event-ID header when given. Deployments still queued are waited for, and
+// finished ones are replayed from their log.
+func streamLog(p provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		if !common.Authorize(writer, request, p.Name(), "Log_Access_Token") {
+			return
+		}
+
+		response := common.CreateResponse()
+
+		id := mux.Vars(request)["deploymentId"]
+		after, _ := strconv.Atoi(request.Header.Get("Last-Event-ID"))
+
+		job := queue.Find(id)
+		if (job == nil || job.Kind != p.Name()) && common.FindLog(p.LogType(), id) == nil {
+			statusCode := http.StatusNotFound
+			response.SetError(common.CreateError(statusCode, "Deployment " + id + " not found."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		eventStream, err := openEventStream(writer)
+		if err != nil {
+			logger.Error("Event stream could not be opened", "provider", p.Name(), "deployment", id, "error", err)
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to open stream."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+		defer eventStream.close()
+
+		lastKeepAlive := time.Now()
+		for {
+			if stream := common.FindStream(p.LogType(), id); stream != nil {
+				followStream(eventStream, stream, after)
+				return
+			}
+			// The log is written before the job leaves the queue, and is only looked up
+			// then, as finding it reads the log store.
+			if queue.Find(id) == nil {
+				if log := common.FindLog(p.LogType(), id); log != nil {
+					for _, event := range common.LogStreamEvents(log) {
+						if event.ID <= after {
+							continue
+						}
+						if err := eventStream.send(event); err != nil {
+							return
+						}
+					}
+				}
+				// Otherwise the job ended without a deployment, as jobs that do not
+				// describe one do.
+				return
+			}
+			time.Sleep(queuedPollInterval)
+			if time.Since(lastKeepAlive) >= keepAliveInterval {
+				if err := eventStream.keepAlive(); err != nil {
+					return
+				}
+				lastKeepAlive = time.Now()
+			}
+		}
+
+	}
+}
+
+// followStream sends the events of a running deployment until it ends, the subscriber
+// disconnects or is dropped for lagging behind.
+func followStream(eventStream *eventStream, stream *common.Stream, after int) {
+	history, events := stream.Subscribe(after)
+	defer stream.Unsubscribe(events)
+
+	for _, event := range history {
+		if err := eventStream.send(event); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := eventStream.send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := eventStream.keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package web_hook
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/features/common"
+	"prolific/provider"
+	"prolific/queue"
+)
+
+const commandUsage = "Usage: `/prolific deploy`, `/prolific redeploy`, `/prolific rollback` or `/prolific status`."
+
+// handleCommand runs a command commented on a pull request and replies to it. Only
+// authors allowed to deploy the repository may run commands.
+func handleCommand(writer http.ResponseWriter, p provider.Provider, event *provider.Event) {
+
+	response := common.CreateResponse()
+	trigger := event.Trigger
+	commandLogger := eventLogger(p, event).With("command", event.Command, "actor", event.Actor)
+
+	commander, ok := p.(provider.Commander)
+	if !ok {
+		response.Message = "Event ignored."
+		common.SendResponse(writer, response)
+		return
+	}
+
+	allowed, err := commander.CanDeploy(event)
+	if err != nil {
+		commandLogger.Error("Permission could not be checked", "error", err)
+		statusCode := http.StatusInternalServerError
+		response.SetError(common.CreateError(statusCode, "Failed to check permission."))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+	if !allowed {
+		commandLogger.Warn("Command not permitted")
+		replyToCommand(p, event, fmt.Sprintf("@%s, only users with write permission on %s/%s can run Prolific commands.",
+			event.Actor, trigger.Owner, trigger.Repository))
+		response.SetError(common.CreateError(http.StatusForbidden, "Command not permitted."))
+		common.SendResponseWithStatusCode(writer, response, http.StatusOK)
+		return
+	}
+
+	if triggerError := checkTrigger(trigger); triggerError != nil {
+		replyToCommand(p, event, triggerError.Reason)
+		response.SetError(triggerError)
+		common.SendResponseWithStatusCode(writer, response, http.StatusOK)
+		return
+	}
+	if watchError := checkWatched(trigger.Owner, trigger.Repository, trigger.Branch); watchError != nil {
+		replyToCommand(p, event, watchError.Reason)
+		response.SetError(watchError)
+		common.SendResponseWithStatusCode(writer, response, http.StatusOK)
+		return
+	}
+
+	key := jobKey(trigger.Repository, trigger.Branch)
+
+	switch event.Command {
+	case provider.CommandDeploy, provider.CommandRedeploy:
+		if event.Command == provider.CommandDeploy {
+			if event.CommitSha == "" {
+				replyToCommand(p, event, fmt.Sprintf("Only merged pull requests can be deployed, use `/prolific %s` to deploy [%s] stage as it is.",
+					provider.CommandRedeploy, trigger.Branch))
+				response.Message = "Command refused."
+				common.SendResponse(writer, response)
+				return
+			}
+			// The merge commit is deployed, rather than whatever the branch has become since.
+			event.Trigger.Ref = event.CommitSha
+		}
+
+		var job *queue.Job
+		if event.Command == provider.CommandDeploy {
+			job, err = queue.EnqueueUnlessPending(p.Name(), key, event)
+		} else {
+			job, err = queue.Enqueue(p.Name(), key, event)
+		}
+		if err == queue.ErrPending {
+			replyToCommand(p, event, fmt.Sprintf("A deployment of [%s] stage is already pending, use `/prolific %s` to deploy it again afterwards.",
+				trigger.Branch, provider.CommandRedeploy))
+			response.Message = "Command refused."
+			common.SendResponse(writer, response)
+			return
+		}
+		if err != nil {
+			commandLogger.Error("Deployment could not be queued", "error", err)
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to queue deployment."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+		commandLogger.Info("Deployment queued", "deployment", job.ID)
+		replyToCommand(p, event, fmt.Sprintf("@%s, deployment `%s` of [%s] stage has been queued.",
+			event.Actor, job.ID, trigger.Branch))
+		response.Message = "Event recorded."
+		response.Data = job
+
+	case provider.CommandRollback:
+		rollback, err := rollbackEvent(p, *event)
+		if err != nil {
+			replyToCommand(p, event, fmt.Sprintf("[%s] stage has no previous successful deployment to roll back to.",
+				trigger.Branch))
+			response.Message = "Command refused."
+			break
+		}
+
+		job, err := queue.Enqueue(p.Name(), key, rollback)
+		if err != nil {
+			commandLogger.Error("Rollback could not be queued", "error", err)
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to queue rollback."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+		commandLogger.Info("Rollback queued", "deployment", job.ID, "rollback_of", rollback.RollbackOf)
+		replyToCommand(p, event, fmt.Sprintf("@%s, rollback `%s` of deployment `%s` of [%s] stage to `%s` has been queued.",
+			event.Actor, job.ID, rollback.RollbackOf, trigger.Branch, rollback.CommitSha))
+		response.Message = "Event recorded."
+		response.Data = job
+
+	case provider.CommandStatus:
+		replyToCommand(p, event, statusComment(p, trigger, queue.Pending(key)))
+		response.Message = "Status sent."
+
+	default:
+		replyToCommand(p, event, commandUsage)
+		response.Message = "Command unknown."
+	}
+
+	common.SendResponse(writer, response)
+
+}
+
+// statusComment describes the pending deployments of the trigger's stage and its last
+// deployment.
+func statusComment(p provider.Provider, trigger provider.Trigger, pendingJobs []queue.Job) string {
+	comment := fmt.Sprintf("Status of [%s] stage of [%s/%s](%s).\n\n",
+		trigger.Branch, trigger.Owner, trigger.Repository, trigger.RepositoryUrl)
+	comment += fmt.Sprintf("| _Key_ | _Value_ |\n|---|---|\n")
+	for _, job := range pendingJobs {
+		if job.Status == queue.StatusRunning {
+			comment += fmt.Sprintf("| Running Deployment | `%s` since %s |\n", job.ID, job.StartedAt)
+		} else {
+			comment += fmt.Sprintf("| Queued Deployment | `%s` since %s |\n", job.ID, job.QueuedAt)
+		}
+	}
+
+	logs := common.QueryLogs(p.LogType(), common.LogQuery{
+		Owner:      trigger.Owner,
+		Repository: trigger.Repository,
+		Branch:     trigger.Branch,
+	})
+	if len(logs) > 0 {
+		log := logs[len(logs)-1]
+		result := "Success"
+		if log.Interrupted {
+			result = "Interrupted"
+		} else if !log.Success {
+			result = "Error"
+		}
+		comment += fmt.Sprintf("| Last Deployment | `%s` |\n", log.ID)
+		comment += fmt.Sprintf("| Last Result | %s |\n", result)
+		comment += fmt.Sprintf("| Last Finish Time | %s |\n", log.EndedAt)
+		return comment
+	}
+
+	comment += fmt.Sprintf("| Last Deployment | None |\n")
+	return comment
+}
+
+func replyToCommand(p provider.Provider, event *provider.Event, comment string) {
+	_, err := p.PostComment(event, comment)
+	if err != nil {
+		eventLogger(p, event).Error("Reply could not be posted", "error", err)
+	}
+}
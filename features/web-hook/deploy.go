@@ -1,28 +1,52 @@
 package web_hook
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"prolific/config"
-	"prolific/debug"
 	"prolific/features/common"
+	"prolific/logger"
+	"prolific/provider"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func deploy(owner string, repository string, branch string) ([]common.ExecutableLog, error) {
+var ErrDeploymentInterrupted = errors.New("deployment interrupted by server shutdown")
 
-	debug.Printf("Deployment Started for Branch %s [%s/%s]\n", branch, owner, repository)
+// deploy runs the pipeline of the event in the working tree of its stage, or, with the
+// releases layout, in a new release that becomes current once every step succeeded.
+// Rollbacks to a release still available only switch back to it. The commit deployed in
+// place is returned once resolved, and is empty when it could not be.
+func deploy(ctx context.Context, event *provider.Event) ([]common.ExecutableLog, *Release, string, error) {
+
+	trigger := event.Trigger
+
+	deploymentTimeout := configuredTimeout("Deployment_Timeout")
+	if deploymentTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deploymentTimeout)
+		defer cancel()
+	}
+
+	repository := trigger.Repository
+	branch := trigger.Branch
+
+	deploymentLogger := logger.FromContext(ctx)
+	deploymentLogger.Info("Deployment started", "trigger", trigger.Type, "ref", trigger.Ref)
 
 	var executableLogs []common.ExecutableLog
+	var commitSha string
 
 	rootPath := filepath.Join(config.Get("Prolific", "Root_Path"))
 	if _, err := os.Stat(rootPath); os.IsNotExist(err) || err != nil {
 		if err != nil {
 			err = errors.New("root path " + rootPath + " does not exist")
-			debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-			return executableLogs, err
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, nil, commitSha, err
 		}
 	}
 
@@ -30,65 +54,221 @@ func deploy(owner string, repository string, branch string) ([]common.Executable
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) || err != nil {
 		if err != nil {
 			err = errors.New("repository path " + repoPath + " does not exist")
-			debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-			return executableLogs, err
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, nil, commitSha, err
 		}
 	}
 
-	suExec, err := common.NewExecutable("su", repoPath)
-	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+	user := config.GetWithDefault("Prolific", "User", "root")
+
+	workPath := repoPath
+	var release *Release
+	if releasesEnabled() && event.Release != "" && releaseExists(repoPath, event.Release) {
+		release = &Release{
+			Name:      event.Release,
+			Path:      filepath.Join(repoPath, ReleasesDirName, event.Release),
+			CommitSha: event.CommitSha,
+		}
+		executableLog := release.activate(repoPath)
+		executableLogs = append(executableLogs, executableLog)
+		if executableLog.Error != "" {
+			err := errors.New("release " + release.Name + " could not be activated: " + executableLog.Error)
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, release, commitSha, err
+		}
+		deploymentLogger.Info("Deployment finished by switching back to a release", "release", release.Name)
+		return executableLogs, release, commitSha, nil
+	} else if releasesEnabled() {
+		var releaseLogs []common.ExecutableLog
+		var err error
+		release, releaseLogs, err = prepareRelease(ctx, repoPath, user, trigger)
+		executableLogs = append(executableLogs, releaseLogs...)
+		if err != nil {
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, nil, commitSha, err
+		}
+		workPath = release.Path
 	}
 
-	makeExec, err := common.NewExecutable("make", repoPath)
+	var pipeline *Pipeline
+	var err error
+	if release != nil {
+		pipeline, err = readPipeline(workPath, trigger, true)
+	} else {
+		var pipelineLogs []common.ExecutableLog
+		pipeline, commitSha, pipelineLogs, err = fetchPipeline(ctx, workPath, user, trigger)
+		executableLogs = append(executableLogs, pipelineLogs...)
+	}
 	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+		deploymentLogger.Error("Deployment finished with error", "error", err)
+		return executableLogs, release, commitSha, err
 	}
 
-	err = checkDependencies(suExec, makeExec)
-	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+	env := triggerEnv(trigger)
+	if release != nil {
+		env = append(env, release.env()...)
 	}
 
-	user := config.GetWithDefault("Prolific", "User", "root")
+	for _, step := range pipeline.Steps {
+		if ctx.Err() != nil {
+			err = interruption(ctx)
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, release, commitSha, err
+		}
+		executableLog, err := runStep(ctx, workPath, user, env, step)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			executableLog.Error = fmt.Sprintf("timed out after %s", deploymentTimeout)
+		}
+		executableLogs = append(executableLogs, executableLog)
+		if err != nil {
+			err = stepError(ctx, step, err)
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, release, commitSha, err
+		}
+	}
 
-	commandString := fmt.Sprintf("'git checkout %s'", branch)
-	executableLog, err := execute(suExec, user, "-c", commandString)
-	executableLogs = append(executableLogs, executableLog)
-	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+	if release != nil {
+		executableLog := release.activate(repoPath)
+		executableLogs = append(executableLogs, executableLog)
+		if executableLog.Error != "" {
+			err = errors.New("release " + release.Name + " could not be activated: " + executableLog.Error)
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, release, commitSha, err
+		}
 	}
 
-	commandString = fmt.Sprintf("'git pull'")
-	executableLog, err = execute(suExec, user, "-c", commandString)
-	executableLogs = append(executableLogs, executableLog)
+	for _, check := range pipeline.HealthChecks {
+		deploymentLogger.Info("Running health check", "check", check.Name, "args", check.describe())
+		executableLog, attemptLogs, err := check.run(ctx, workPath, user, env)
+		executableLogs = append(append(executableLogs, executableLog), attemptLogs...)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = interruption(ctx)
+			} else {
+				err = &HealthCheckError{ Check: check.Name, Reason: err.Error() }
+			}
+			deploymentLogger.Error("Deployment finished with error", "error", err)
+			return executableLogs, release, commitSha, err
+		}
+	}
+
+	if release != nil {
+		pruneReleases(ctx, repoPath, keptReleases())
+	}
+
+	deploymentLogger.Info("Deployment finished successfully")
+	return executableLogs, release, commitSha, nil
+
+}
+
+// stepError describes the failure of a step, which may be due to the interruption of
+// the deployment.
+func stepError(ctx context.Context, step PipelineStep, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("step %s failed: %s", step.Name, interruption(ctx).Error())
+	}
+	if ctx.Err() != nil {
+		return interruption(ctx)
+	}
+	return fmt.Errorf("step %s failed: %s", step.Name, err.Error())
+}
+
+// interruption describes why the context of a deployment is done, which is either the
+// deployment timeout or the shutdown of Prolific.
+func interruption(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("deployment timed out after %s", configuredTimeout("Deployment_Timeout"))
+	}
+	return ErrDeploymentInterrupted
+}
+
+// outputLimit is the number of bytes kept of each output of a step, the last ones, as
+// configured by PROLIFIC_OUTPUT_LIMIT, which is 0 to keep the whole outputs.
+func outputLimit() int {
+	limit, err := strconv.Atoi(config.GetWithDefault("Prolific", "Output_Limit", "1048576"))
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// configuredTimeout returns the duration configured under the key of the Prolific
+// module, or 0 when none or an invalid one is configured.
+func configuredTimeout(key string) time.Duration {
+	value := config.Get("Prolific", key)
+	if value == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		logger.Warn("Invalid PROLIFIC_" + strings.ToUpper(key) + " ignored", "value", value)
+		return 0
+	}
+	return timeout
+}
+
+func runStep(ctx context.Context, repoPath string, user string, env []string, step PipelineStep) (common.ExecutableLog, error) {
+	executableLog := common.ExecutableLog{ Step: step.Name }
+
+	workDir, err := step.workDir(repoPath)
 	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+		executableLog.Error = err.Error()
+		return executableLog, err
 	}
 
-	commandString = fmt.Sprintf("'make'")
-	executableLog, err = execute(suExec, user, "-c", commandString)
-	executableLogs = append(executableLogs, executableLog)
+	argv := step.argv()
+	stepExec, err := common.NewExecutable(argv[0], workDir)
 	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+		executableLog.Error = err.Error()
+		return executableLog, err
 	}
 
-	executableLog, err = execute(makeExec, "deploy")
-	executableLogs = append(executableLogs, executableLog)
+	err = checkDependencies(stepExec)
 	if err != nil {
-		debug.Printf("Deployment Finished with Error (Reason: %s)\n", err.Error())
-		return executableLogs, err
+		executableLog.Error = err.Error()
+		return executableLog, err
+	}
+
+	stepExec.User = user
+	if step.User != "" {
+		stepExec.User = step.User
+	}
+	stepExec.Env = append(append([]string{}, env...), step.env()...)
+	stepExec.Timeout, _ = step.timeout()
+	if stepExec.Timeout == 0 {
+		stepExec.Timeout = configuredTimeout("Step_Timeout")
 	}
+	stepExec.OutputLimit = outputLimit()
 
-	debug.Println("Deployment Finished Successfully")
-	return executableLogs, nil
+	stream := common.StreamFromContext(ctx)
+	stepExec.OnLine = func(name string, line string) {
+		stream.Publish(common.StreamOutputEvent, common.StreamOutput{ Step: step.Name, Stream: name, Line: line })
+	}
+	firstEventID := stream.Publish(common.StreamStepEvent, common.StreamStep{ Step: step.Name, Args: step.describe() })
+
+	logger.FromContext(ctx).Info("Running step", "step", step.Name, "user", stepExec.User, "args", step.describe())
+	executableLog, err = stepExec.Run(ctx, argv[1:]...)
+	executableLog.Step = step.Name
+	executableLog.FirstEventID = firstEventID
+	executableLog.LastEventID = stream.Publish(common.StreamStepEndEvent, common.StreamStepEnd{
+		Step:        step.Name,
+		ExitCode:    executableLog.ExitCode,
+		TimeElapsed: executableLog.TimeElapsed,
+		Error:       executableLog.Error,
+	})
+	return executableLog, err
+}
 
+// triggerEnv describes the deployment to the pipeline steps through environment
+// variables, so that a pipeline can check out PROLIFIC_REF itself.
+func triggerEnv(trigger provider.Trigger) []string {
+	return []string{
+		"PROLIFIC_TRIGGER=" + trigger.Type,
+		"PROLIFIC_OWNER=" + trigger.Owner,
+		"PROLIFIC_REPOSITORY=" + trigger.Repository,
+		"PROLIFIC_BRANCH=" + trigger.Branch,
+		"PROLIFIC_REF=" + trigger.Ref,
+	}
 }
 
 func checkDependencies(executables ...*common.Executable) error {
@@ -99,19 +279,3 @@ func checkDependencies(executables ...*common.Executable) error {
 	}
 	return nil
 }
-
-func execute(executable *common.Executable, args ...string) (common.ExecutableLog, error) {
-	output, err := executable.Run(args...)
-	message := ""
-	if err != nil {
-		message = err.Error()
-	}
-	executableLog := common.ExecutableLog{
-		Name:		executable.Path,
-		Args:		strings.Join(append([]string{ executable.Path }, args...), " "),
-		WorkDir:	executable.WorkingDirectory,
-		Output:		output,
-		Error:		message,
-	}
-	return executableLog, err
-}
\ No newline at end of file
@@ -0,0 +1,134 @@
+package web_hook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"prolific/features/common"
+	"prolific/logger"
+	"prolific/provider"
+	"prolific/queue"
+)
+
+// verificationReasons are the responses to verification errors of providers. Other
+// errors are reported as invalid signatures.
+var verificationReasons = map[error]string{
+	provider.ErrNoSignature:     "No signature provided.",
+	provider.ErrInvalidSignature: "Invalid signature provided.",
+	provider.ErrSHA1NotAccepted: "SHA-1 signature not accepted, configure a SHA-256 signature.",
+	provider.ErrNoToken:         "No token provided.",
+	provider.ErrInvalidToken:    "Invalid token provided.",
+}
+
+// deliveryHeaders carry the ID providers give to each webhook delivery.
+var deliveryHeaders = []string{
+	"X-GitHub-Delivery",
+	"X-Gitea-Delivery",
+	"X-Forgejo-Delivery",
+	"X-Gitlab-Event-UUID",
+	"X-Request-UUID",
+}
+
+// deliveryID returns the ID of the webhook delivery, or an empty string when the
+// provider gave none.
+func deliveryID(header http.Header) string {
+	for _, name := range deliveryHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// eventLogger returns the logger of the entries about an event, carrying its provider,
+// delivery, if any, and stage.
+func eventLogger(p provider.Provider, event *provider.Event) *logger.Logger {
+	trigger := event.Trigger
+	fields := []interface{}{ "provider", p.Name() }
+	if event.Delivery != "" {
+		fields = append(fields, "delivery", event.Delivery)
+	}
+	fields = append(fields, "owner", trigger.Owner, "repository", trigger.Repository, "branch", trigger.Branch)
+	return logger.With(fields...)
+}
+
+// webHook returns the handler verifying, parsing and queueing the webhook deliveries
+// of a provider.
+func webHook(p provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		response := common.CreateResponse()
+		delivery := deliveryID(request.Header)
+
+		webHookBody, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to read payload."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		err = p.Verify(request.Header, webHookBody)
+		if err != nil {
+			reason, ok := verificationReasons[err]
+			if !ok {
+				reason = verificationReasons[provider.ErrInvalidSignature]
+			}
+			statusCode := http.StatusBadRequest
+			response.SetError(common.CreateError(statusCode, reason))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			logger.Warn("Delivery rejected", "provider", p.Name(), "delivery", delivery, "error", err)
+			return
+		}
+
+		event, err := p.Parse(request.Header, webHookBody)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to parse payload."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			logger.Error("Delivery could not be parsed", "provider", p.Name(), "delivery", delivery, "error", err)
+			return
+		}
+		if event != nil {
+			event.Delivery = delivery
+		}
+
+		if event != nil && event.Command != "" {
+			handleCommand(writer, p, event)
+			return
+		}
+
+		if event == nil || !applyTriggerRules(&event.Trigger) {
+			response.Message = "Event ignored."
+			common.SendResponse(writer, response)
+			logger.Debug("Delivery ignored", "provider", p.Name(), "delivery", delivery)
+			return
+		}
+
+		trigger := event.Trigger
+		if triggerError := checkTrigger(trigger); triggerError != nil {
+			response.SetError(triggerError)
+			common.SendResponseWithStatusCode(writer, response, http.StatusOK)
+			return
+		}
+		if watchError := checkWatched(trigger.Owner, trigger.Repository, trigger.Branch); watchError != nil {
+			response.SetError(watchError)
+			common.SendResponseWithStatusCode(writer, response, http.StatusOK)
+			return
+		}
+
+		job, err := queue.Enqueue(p.Name(), jobKey(trigger.Repository, trigger.Branch), event)
+		if err != nil {
+			eventLogger(p, event).Error("Deployment could not be queued", "error", err)
+			statusCode := http.StatusInternalServerError
+			response.SetError(common.CreateError(statusCode, "Failed to queue deployment."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		eventLogger(p, event).Info("Deployment queued", "deployment", job.ID, "trigger", trigger.Type, "ref", trigger.Ref)
+		response.Message = "Event recorded."
+		response.Data = job
+		common.SendResponse(writer, response)
+
+	}
+}
@@ -0,0 +1,214 @@
+package web_hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"prolific/features/common"
+	"strings"
+	"time"
+)
+
+const (
+	HealthCheckStep			= "health_check"
+	defaultHealthRetries	= 3
+	defaultHealthInterval	= 5 * time.Second
+	defaultHealthDeadline	= time.Minute
+	healthAttemptTimeout	= 10 * time.Second
+)
+
+// HttpHealthCheck expects a GET of the URL to answer with the status, 200 by default,
+// and a body containing Body when given.
+type HttpHealthCheck struct {
+	Url		string	`yaml:"url"`
+	Status	int		`yaml:"status"`
+	Body	string	`yaml:"body"`
+}
+
+// HealthCheck is run once the last step of a deployment succeeded, and is attempted
+// again, up to Retries times, until it passes or its deadline is reached. Exactly one
+// of Http, Tcp, an address expected to accept connections, or Command is given.
+type HealthCheck struct {
+	Name		string				`yaml:"name"`
+	Http		*HttpHealthCheck	`yaml:"http"`
+	Tcp			string				`yaml:"tcp"`
+	Command		string				`yaml:"command"`
+	User		string				`yaml:"user"`
+	Retries		*int				`yaml:"retries"`
+	Interval	string				`yaml:"interval"`
+	Deadline	string				`yaml:"deadline"`
+}
+
+// HealthCheckError is the failure of a deployment whose health check did not pass.
+type HealthCheckError struct {
+	Check	string
+	Reason	string
+}
+
+func (err *HealthCheckError) Error() string {
+	return fmt.Sprintf("health check %s failed: %s", err.Check, err.Reason)
+}
+
+func (check HealthCheck) validate() error {
+	kinds := 0
+	if check.Http != nil {
+		kinds++
+		if check.Http.Url == "" {
+			return errors.New("no url given")
+		}
+	}
+	if check.Tcp != "" {
+		kinds++
+	}
+	if strings.TrimSpace(check.Command) != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return errors.New("exactly one of http, tcp or command must be given")
+	}
+	if check.Retries != nil && *check.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if _, err := parseDuration(check.Interval, defaultHealthInterval); err != nil {
+		return errors.New("invalid interval: " + err.Error())
+	}
+	if _, err := parseDuration(check.Deadline, defaultHealthDeadline); err != nil {
+		return errors.New("invalid deadline: " + err.Error())
+	}
+	return nil
+}
+
+// run attempts the health check until it passes, its retries are exhausted or its
+// deadline is reached, and logs every attempt. The logs of the attempts of a command
+// check, with their outputs, are returned as well.
+func (check HealthCheck) run(ctx context.Context, workPath string, user string, env []string) (executableLog common.ExecutableLog, attemptLogs []common.ExecutableLog, err error) {
+	executableLog = common.ExecutableLog{
+		Step:    HealthCheckStep,
+		Name:    check.Name,
+		Args:    check.describe(),
+		WorkDir: workPath,
+	}
+
+	retries := defaultHealthRetries
+	if check.Retries != nil {
+		retries = *check.Retries
+	}
+	interval, _ := parseDuration(check.Interval, defaultHealthInterval)
+	deadline, _ := parseDuration(check.Deadline, defaultHealthDeadline)
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	stream := common.StreamFromContext(ctx)
+	executableLog.FirstEventID = stream.Publish(common.StreamStepEvent, common.StreamStep{ Step: HealthCheckStep, Args: executableLog.Args })
+	report := func(line string) {
+		executableLog.Output += line + "\n"
+		stream.Publish(common.StreamOutputEvent, common.StreamOutput{ Step: HealthCheckStep, Stream: "stdout", Line: line })
+	}
+
+	start := time.Now()
+	defer func() {
+		end := time.Now()
+		executableLog.StartedAt = start.Format(time.RFC1123)
+		executableLog.EndedAt = end.Format(time.RFC1123)
+		executableLog.TimeElapsed = end.Sub(start).String()
+		executableLog.LastEventID = stream.Publish(common.StreamStepEndEvent, common.StreamStepEnd{
+			Step:        HealthCheckStep,
+			TimeElapsed: executableLog.TimeElapsed,
+			Error:       executableLog.Error,
+		})
+	}()
+
+	for attempt := 1; ; attempt++ {
+		var attemptLog *common.ExecutableLog
+		attemptLog, err = check.attempt(deadlineCtx, workPath, user, env)
+		if attemptLog != nil {
+			attemptLogs = append(attemptLogs, *attemptLog)
+		}
+		if err == nil {
+			report(fmt.Sprintf("attempt %d: passed", attempt))
+			return executableLog, attemptLogs, nil
+		}
+		report(fmt.Sprintf("attempt %d: %s", attempt, err.Error()))
+		if attempt > retries {
+			break
+		}
+		select {
+		case <-deadlineCtx.Done():
+		case <-time.After(interval):
+		}
+		if deadlineCtx.Err() != nil {
+			if ctx.Err() == nil {
+				err = fmt.Errorf("deadline of %s reached", deadline)
+			}
+			break
+		}
+	}
+	executableLog.Error = err.Error()
+	return executableLog, attemptLogs, err
+}
+
+// attempt runs the health check once, and returns the log of the command of a command
+// check.
+func (check HealthCheck) attempt(ctx context.Context, workPath string, user string, env []string) (*common.ExecutableLog, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, healthAttemptTimeout)
+	defer cancel()
+
+	switch {
+	case check.Http != nil:
+		request, err := http.NewRequest(http.MethodGet, check.Http.Url, nil)
+		if err != nil {
+			return nil, err
+		}
+		response, err := http.DefaultClient.Do(request.WithContext(attemptCtx))
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return nil, err
+		}
+		status := check.Http.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if response.StatusCode != status {
+			return nil, fmt.Errorf("status %d, expected %d", response.StatusCode, status)
+		}
+		if !strings.Contains(string(body), check.Http.Body) {
+			return nil, fmt.Errorf("body does not contain %q", check.Http.Body)
+		}
+		return nil, nil
+	case check.Tcp != "":
+		var dialer net.Dialer
+		connection, err := dialer.DialContext(attemptCtx, "tcp", check.Tcp)
+		if err != nil {
+			return nil, err
+		}
+		return nil, connection.Close()
+	}
+	step := PipelineStep{ Name: check.Name, Command: check.Command, User: check.User }
+	executableLog, err := runStep(attemptCtx, workPath, user, env, step)
+	return &executableLog, err
+}
+
+func (check HealthCheck) describe() string {
+	switch {
+	case check.Http != nil:
+		return "GET " + check.Http.Url
+	case check.Tcp != "":
+		return "tcp " + check.Tcp
+	}
+	return check.Command
+}
+
+func parseDuration(value string, defaultDuration time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultDuration, nil
+	}
+	return time.ParseDuration(value)
+}
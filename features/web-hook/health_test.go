@@ -0,0 +1,91 @@
+package web_hook
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closedAddress returns an address nothing listens on.
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestHealthCheckRetries(t *testing.T) {
+	retries := 2
+	check := HealthCheck{ Name: "port", Tcp: closedAddress(t), Retries: &retries, Interval: "10ms" }
+
+	executableLog, attemptLogs, err := check.run(context.Background(), ".", "", nil)
+	if err == nil {
+		t.Fatal("expected the health check to fail")
+	}
+	if attempts := strings.Count(executableLog.Output, "attempt "); attempts != retries + 1 {
+		t.Errorf("expected %d attempts, got %d: %s", retries + 1, attempts, executableLog.Output)
+	}
+	if executableLog.Error == "" || len(attemptLogs) != 0 {
+		t.Errorf("expected the failure to be logged without attempt logs, got %+v, %d", executableLog, len(attemptLogs))
+	}
+}
+
+func TestHealthCheckPassesOnRetry(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if listener, err := net.Listen("tcp", address); err == nil {
+			defer listener.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	retries := 20
+	check := HealthCheck{ Name: "port", Tcp: address, Retries: &retries, Interval: "20ms" }
+	executableLog, _, err := check.run(context.Background(), ".", "", nil)
+	if err != nil || !strings.Contains(executableLog.Output, "passed") {
+		t.Fatalf("expected the health check to pass once listened to, got %v: %s", err, executableLog.Output)
+	}
+}
+
+func TestHealthCheckDeadline(t *testing.T) {
+	retries := 1000
+	check := HealthCheck{ Name: "port", Tcp: closedAddress(t), Retries: &retries, Interval: "20ms", Deadline: "150ms" }
+
+	start := time.Now()
+	_, _, err := check.run(context.Background(), ".", "", nil)
+	if err == nil || err.Error() != "deadline of 150ms reached" {
+		t.Fatalf("expected the deadline to be reached, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the check to stop at its deadline, took %s", elapsed)
+	}
+}
+
+func TestCommandHealthCheckLogsAttempts(t *testing.T) {
+	retries := 1
+	check := HealthCheck{ Name: "smoke", Command: "echo checking; echo refused >&2; exit 1", Retries: &retries, Interval: "10ms" }
+
+	_, attemptLogs, err := check.run(context.Background(), ".", "", nil)
+	if err == nil {
+		t.Fatal("expected the health check to fail")
+	}
+	if len(attemptLogs) != 2 {
+		t.Fatalf("expected the log of both attempts, got %d", len(attemptLogs))
+	}
+	for _, attemptLog := range attemptLogs {
+		if attemptLog.Stdout != "checking\n" || attemptLog.Stderr != "refused\n" || attemptLog.ExitCode == nil || *attemptLog.ExitCode != 1 {
+			t.Errorf("expected the outputs and exit code of the attempt, got %+v", attemptLog)
+		}
+	}
+}
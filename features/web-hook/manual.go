@@ -0,0 +1,211 @@
+package web_hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"prolific/features/common"
+	"prolific/provider"
+	"prolific/queue"
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultProviderName	= "github"
+	ManualEvent			= "api"
+)
+
+var commitShaPattern = regexp.MustCompile("^[0-9a-f]{7,40}$")
+
+// DeployRequest is the optional body of a manual deployment. The deployment is logged
+// and reported through the provider, GitHub by default.
+type DeployRequest struct {
+	Provider	string	`json:"provider"`
+	CommitSha	string	`json:"commit_sha"`
+	TriggeredBy	string	`json:"triggered_by"`
+	Reason		string	`json:"reason"`
+}
+
+// manualDeploy returns the handler queueing the deployment of a watched branch, at its
+// latest commit or at the commit given, on behalf of whoever triggered it.
+func manualDeploy(providers []provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		response := common.CreateResponse()
+
+		p, deployRequest, trigger, ok := readDeployRequest(writer, request, providers)
+		if !ok {
+			return
+		}
+
+		commitSha := strings.ToLower(deployRequest.CommitSha)
+		if commitSha != "" && !commitShaPattern.MatchString(commitSha) {
+			statusCode := http.StatusBadRequest
+			response.SetError(common.CreateError(statusCode, "Invalid commit SHA."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		if commitSha != "" {
+			trigger.Ref = commitSha
+		}
+		event := &provider.Event{
+			Trigger:   trigger,
+			CommitSha: commitSha,
+			Actor:     deployRequest.TriggeredBy,
+			Reason:    deployRequest.Reason,
+		}
+
+		queueManualDeployment(writer, p, event)
+
+	}
+}
+
+// manualRollback returns the handler queueing the rollback of the last deployment of a
+// watched branch, on behalf of whoever triggered it.
+func manualRollback(providers []provider.Provider) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		response := common.CreateResponse()
+
+		p, deployRequest, trigger, ok := readDeployRequest(writer, request, providers)
+		if !ok {
+			return
+		}
+
+		event, err := rollbackEvent(p, provider.Event{
+			Trigger: trigger,
+			Actor:   deployRequest.TriggeredBy,
+			Reason:  deployRequest.Reason,
+		})
+		if err != nil {
+			statusCode := http.StatusConflict
+			response.SetError(common.CreateError(statusCode, "No previous successful deployment to roll back to."))
+			common.SendResponseWithStatusCode(writer, response, statusCode)
+			return
+		}
+
+		queueManualDeployment(writer, p, event)
+
+	}
+}
+
+// readDeployRequest authorizes and validates a manual deployment request, and returns
+// the provider it goes through, the request and the manual trigger of the branch. The
+// error response is sent when ok is false.
+func readDeployRequest(writer http.ResponseWriter, request *http.Request, providers []provider.Provider) (p provider.Provider, deployRequest DeployRequest, trigger provider.Trigger, ok bool) {
+
+	response := common.CreateResponse()
+
+	owner, repository, branch := splitDeployTarget(mux.Vars(request)["target"])
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err == nil && len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, &deployRequest)
+	}
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		response.SetError(common.CreateError(statusCode, "Invalid deployment request."))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+
+	providerName := deployRequest.Provider
+	if providerName == "" {
+		providerName = DefaultProviderName
+	}
+	for _, candidate := range providers {
+		if candidate.Name() == providerName {
+			p = candidate
+		}
+	}
+	if p == nil {
+		statusCode := http.StatusBadRequest
+		response.SetError(common.CreateError(statusCode, fmt.Sprintf("Provider %s not supported.", providerName)))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+
+	if !common.Authorize(writer, request, p.Name(), "Deploy_Access_Token") {
+		return
+	}
+
+	if strings.TrimSpace(deployRequest.TriggeredBy) == "" {
+		statusCode := http.StatusBadRequest
+		response.SetError(common.CreateError(statusCode, "No triggered_by provided."))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+
+	trigger = provider.Trigger{
+		Type:          provider.ManualTrigger,
+		Event:         ManualEvent,
+		Owner:         owner,
+		Repository:    repository,
+		Branch:        branch,
+		Ref:           branch,
+		RepositoryUrl: p.RepositoryUrl(owner, repository),
+		Name:          fmt.Sprintf("manual deployment by %s", deployRequest.TriggeredBy),
+	}
+
+	if triggerError := checkTrigger(trigger); triggerError != nil {
+		response.SetError(triggerError)
+		common.SendResponseWithStatusCode(writer, response, http.StatusBadRequest)
+		return
+	}
+	if watchError := checkWatched(owner, repository, branch); watchError != nil {
+		response.SetError(watchError)
+		common.SendResponseWithStatusCode(writer, response, http.StatusBadRequest)
+		return
+	}
+	return p, deployRequest, trigger, true
+
+}
+
+// splitDeployTarget splits <owner>/<repository>/<branch> into its names. Owners, such as
+// GitLab subgroups, and branches may both hold slashes, so the owner is the longest
+// watched owner followed by a watched repository, and the first segment otherwise.
+func splitDeployTarget(target string) (owner string, repository string, branch string) {
+	segments := strings.Split(target, "/")
+	ownerLength := 1
+	for length := len(segments) - 2; length > 1; length-- {
+		candidate := strings.Join(segments[:length], "/")
+		if isWatched(candidate, OwnerElementKey) && isWatched(segments[length], RepositoryElementKey) {
+			ownerLength = length
+			break
+		}
+	}
+	if len(segments) < ownerLength + 2 {
+		return target, "", ""
+	}
+	owner = strings.Join(segments[:ownerLength], "/")
+	repository = segments[ownerLength]
+	branch = strings.Join(segments[ownerLength + 1:], "/")
+	return owner, repository, branch
+}
+
+func queueManualDeployment(writer http.ResponseWriter, p provider.Provider, event *provider.Event) {
+
+	response := common.CreateResponse()
+	trigger := event.Trigger
+	manualLogger := eventLogger(p, event).With("actor", event.Actor)
+
+	job, err := queue.Enqueue(p.Name(), jobKey(trigger.Repository, trigger.Branch), event)
+	if err != nil {
+		manualLogger.Error("Deployment could not be queued", "error", err)
+		statusCode := http.StatusInternalServerError
+		response.SetError(common.CreateError(statusCode, "Failed to queue deployment."))
+		common.SendResponseWithStatusCode(writer, response, statusCode)
+		return
+	}
+	manualLogger.Info("Manual deployment queued", "deployment", job.ID, "trigger", trigger.Type, "ref", trigger.Ref,
+		"reason", event.Reason)
+
+	response.Message = "Deployment queued."
+	response.Data = job
+	common.SendResponse(writer, response)
+
+}
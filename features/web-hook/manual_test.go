@@ -0,0 +1,69 @@
+package web_hook
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"prolific/config"
+	"strings"
+	"testing"
+)
+
+func TestSplitDeployTarget(t *testing.T) {
+	config.Set("watch", OwnerElementKey, "acme;group;group/subgroup")
+	config.Set("watch", RepositoryElementKey, "shop")
+	defer config.Set("watch", OwnerElementKey, "")
+	defer config.Set("watch", RepositoryElementKey, "")
+
+	tests := []struct {
+		target		string
+		owner		string
+		repository	string
+		branch		string
+	}{
+		{ "acme/shop/main", "acme", "shop", "main" },
+		{ "acme/shop/release/1.0", "acme", "shop", "release/1.0" },
+		{ "group/subgroup/shop/main", "group/subgroup", "shop", "main" },
+		{ "group/subgroup/shop/feature/a/b", "group/subgroup", "shop", "feature/a/b" },
+		// Without a watched owner followed by a watched repository, the owner is the first
+		// segment, and the deployment is then refused as not watched.
+		{ "other/blog/main", "other", "blog", "main" },
+		{ "acme/shop", "acme/shop", "", "" },
+		{ "acme", "acme", "", "" },
+	}
+	for _, test := range tests {
+		owner, repository, branch := splitDeployTarget(test.target)
+		if owner != test.owner || repository != test.repository || branch != test.branch {
+			t.Errorf("%s: expected %q %q %q, got %q %q %q", test.target,
+				test.owner, test.repository, test.branch, owner, repository, branch)
+		}
+	}
+}
+
+func TestDeployRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	route := DeployRoute{}
+	route.Initialise(router.PathPrefix("/deploy").Subrouter())
+
+	tests := []struct {
+		path		string
+		rollback	bool
+		target		string
+	}{
+		{ "/deploy/acme/shop/main", false, "acme/shop/main" },
+		{ "/deploy/acme/shop/release/1.0", false, "acme/shop/release/1.0" },
+		{ "/deploy/acme/shop/release/1.0/rollback", true, "acme/shop/release/1.0" },
+		{ "/deploy/group/subgroup/shop/main/rollback", true, "group/subgroup/shop/main" },
+	}
+	for _, test := range tests {
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(http.MethodPost, test.path, nil), &match) {
+			t.Fatalf("%s: expected a route", test.path)
+		}
+		template, _ := match.Route.GetPathTemplate()
+		if rollback := strings.HasSuffix(template, "/rollback"); rollback != test.rollback || match.Vars["target"] != test.target {
+			t.Errorf("%s: expected rollback %v of %s, got %s of %s", test.path, test.rollback, test.target,
+				template, match.Vars["target"])
+		}
+	}
+}
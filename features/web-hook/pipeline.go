@@ -0,0 +1,243 @@
+package web_hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"prolific/features/common"
+	"prolific/provider"
+	"sort"
+	"strings"
+	"time"
+)
+
+const PipelineFileName = ".prolific.yml"
+
+// PipelineStep is a single named command declared in the repository's pipeline file.
+// Command is run by the shell, while Args is run directly, its first element being the
+// executable and the others its arguments. Exactly one of them is given.
+type PipelineStep struct {
+	Name				string				`yaml:"name"`
+	Command				string				`yaml:"command"`
+	Args				[]string			`yaml:"args"`
+	WorkingDirectory	string				`yaml:"working_directory"`
+	User				string				`yaml:"user"`
+	Env					map[string]string	`yaml:"env"`
+	Timeout				string				`yaml:"timeout"`
+}
+
+// Pipeline is the list of steps run, in order, to deploy a repository, and the health
+// checks the deployed service must then pass.
+type Pipeline struct {
+	Steps			[]PipelineStep	`yaml:"steps"`
+	HealthChecks	[]HealthCheck	`yaml:"health_checks"`
+}
+
+// defaultPipeline reproduces the sequence used before pipeline files existed, and is
+// used for repositories that do not carry a pipeline file. As before, only the final
+// deploy step runs as root. Tags, and commits deployed manually, are fetched and checked
+// out instead of pulled. Releases are already checked out, so they are only built and
+// deployed. Refs are passed as arguments, never through a shell.
+func defaultPipeline(trigger provider.Trigger, released bool) *Pipeline {
+	if released {
+		return &Pipeline{
+			Steps: []PipelineStep{
+				{ Name: "build", Args: []string{ "make" } },
+				{ Name: "deploy", Args: []string{ "make", "deploy" }, User: "root" },
+			},
+		}
+	}
+	if trigger.Type == provider.TagTrigger {
+		return &Pipeline{
+			Steps: []PipelineStep{
+				{ Name: "fetch", Args: []string{ "git", "fetch", "--tags" } },
+				{ Name: "checkout", Args: []string{ "git", "checkout", "tags/" + trigger.Ref } },
+				{ Name: "build", Args: []string{ "make" } },
+				{ Name: "deploy", Args: []string{ "make", "deploy" }, User: "root" },
+			},
+		}
+	}
+	if deploysCommit(trigger) {
+		return &Pipeline{
+			Steps: []PipelineStep{
+				{ Name: "fetch", Args: []string{ "git", "fetch" } },
+				{ Name: "checkout", Args: []string{ "git", "checkout", trigger.Ref } },
+				{ Name: "build", Args: []string{ "make" } },
+				{ Name: "deploy", Args: []string{ "make", "deploy" }, User: "root" },
+			},
+		}
+	}
+	branch := trigger.Ref
+	return &Pipeline{
+		Steps: []PipelineStep{
+			{ Name: "checkout", Args: []string{ "git", "checkout", branch } },
+			{ Name: "pull", Args: []string{ "git", "pull" } },
+			{ Name: "build", Args: []string{ "make" } },
+			{ Name: "deploy", Args: []string{ "make", "deploy" }, User: "root" },
+		},
+	}
+}
+
+// readPipeline parses the pipeline file at the root of repoPath, falling back to the
+// default pipeline when the repository does not have one.
+func readPipeline(repoPath string, trigger provider.Trigger, released bool) (*Pipeline, error) {
+	pipelinePath := filepath.Join(repoPath, PipelineFileName)
+	content, err := ioutil.ReadFile(pipelinePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultPipeline(trigger, released), nil
+		}
+		return nil, err
+	}
+	return parsePipeline(content, repoPath)
+}
+
+// fetchPipeline fetches the working tree of repoPath, and parses the pipeline file of
+// the commit the trigger deploys rather than the one of the working tree, which only the
+// pipeline brings to that commit, which it returns. Working trees that are not git
+// clones are read as they are, and no commit is returned.
+func fetchPipeline(ctx context.Context, repoPath string, user string, trigger provider.Trigger) (*Pipeline, string, []common.ExecutableLog, error) {
+	var executableLogs []common.ExecutableLog
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		pipeline, err := readPipeline(repoPath, trigger, false)
+		return pipeline, "", executableLogs, err
+	}
+
+	steps := []PipelineStep{
+		{ Name: "fetch", Args: []string{ "git", "fetch", "--quiet", "--tags", "origin" } },
+		{ Name: "resolve", Args: []string{ "git", "rev-parse", "--verify", deployedRef(trigger) + "^{commit}" } },
+	}
+	for _, step := range steps {
+		executableLog, err := runStep(ctx, repoPath, user, triggerEnv(trigger), step)
+		executableLogs = append(executableLogs, executableLog)
+		if err != nil {
+			return nil, "", executableLogs, stepError(ctx, step, err)
+		}
+	}
+	commitSha := strings.TrimSpace(executableLogs[len(executableLogs)-1].Stdout)
+	if !commitShaPattern.MatchString(commitSha) {
+		return nil, "", executableLogs, errors.New("commit of " + trigger.Ref + " could not be resolved")
+	}
+
+	step := PipelineStep{ Name: "pipeline", Args: []string{ "git", "ls-tree", "--name-only", commitSha, "--", PipelineFileName } }
+	executableLog, err := runStep(ctx, repoPath, user, triggerEnv(trigger), step)
+	executableLogs = append(executableLogs, executableLog)
+	if err != nil {
+		return nil, "", executableLogs, stepError(ctx, step, err)
+	}
+	if strings.TrimSpace(executableLog.Stdout) != PipelineFileName {
+		return defaultPipeline(trigger, false), commitSha, executableLogs, nil
+	}
+
+	step = PipelineStep{ Name: "pipeline", Args: []string{ "git", "show", commitSha + ":" + PipelineFileName } }
+	executableLog, err = runStep(ctx, repoPath, user, triggerEnv(trigger), step)
+	executableLogs = append(executableLogs, executableLog)
+	if err != nil {
+		return nil, "", executableLogs, stepError(ctx, step, err)
+	}
+	if executableLog.Truncated {
+		return nil, "", executableLogs, errors.New("pipeline file " + PipelineFileName + " exceeds the output limit")
+	}
+	pipeline, err := parsePipeline([]byte(executableLog.Stdout), repoPath)
+	return pipeline, commitSha, executableLogs, err
+}
+
+func parsePipeline(content []byte, repoPath string) (*Pipeline, error) {
+	var pipeline Pipeline
+	err := yaml.Unmarshal(content, &pipeline)
+	if err != nil {
+		return nil, errors.New("pipeline file " + PipelineFileName + " is invalid: " + err.Error())
+	}
+	err = pipeline.validate(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+func (pipeline *Pipeline) validate(repoPath string) error {
+	if len(pipeline.Steps) == 0 {
+		return errors.New("pipeline file " + PipelineFileName + " declares no steps")
+	}
+	for index, step := range pipeline.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("pipeline step #%d has no name", index + 1)
+		}
+		hasCommand := strings.TrimSpace(step.Command) != ""
+		if hasCommand == (len(step.Args) > 0) {
+			return fmt.Errorf("pipeline step %s must have exactly one of command or args", step.Name)
+		}
+		if len(step.Args) > 0 && step.Args[0] == "" {
+			return fmt.Errorf("pipeline step %s has no executable", step.Name)
+		}
+		if _, err := step.timeout(); err != nil {
+			return fmt.Errorf("pipeline step %s has an invalid timeout: %s", step.Name, err.Error())
+		}
+		if _, err := step.workDir(repoPath); err != nil {
+			return fmt.Errorf("pipeline step %s: %s", step.Name, err.Error())
+		}
+	}
+	for index, check := range pipeline.HealthChecks {
+		if check.Name == "" {
+			return fmt.Errorf("health check #%d has no name", index + 1)
+		}
+		if err := check.validate(); err != nil {
+			return fmt.Errorf("health check %s: %s", check.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (step PipelineStep) timeout() (time.Duration, error) {
+	if step.Timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(step.Timeout)
+}
+
+// workDir resolves the step's working directory relative to the repository, refusing
+// directories outside of it.
+func (step PipelineStep) workDir(repoPath string) (string, error) {
+	workDir := filepath.Join(repoPath, step.WorkingDirectory)
+	relativePath, err := filepath.Rel(repoPath, workDir)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".." + string(filepath.Separator)) {
+		return "", errors.New("working directory " + step.WorkingDirectory + " is outside of the repository")
+	}
+	return workDir, nil
+}
+
+// argv returns the executable of the step followed by its arguments. Commands are run
+// by the shell.
+func (step PipelineStep) argv() []string {
+	if len(step.Args) > 0 {
+		return step.Args
+	}
+	return []string{ "sh", "-c", step.Command }
+}
+
+// describe returns the step as it is shown in the debug output.
+func (step PipelineStep) describe() string {
+	if len(step.Args) > 0 {
+		return strings.Join(step.Args, " ")
+	}
+	return step.Command
+}
+
+func (step PipelineStep) env() []string {
+	var env []string
+	for key, value := range step.Env {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// deploysCommit reports whether the trigger deploys the commit of its ref, rather than a
+// branch or a tag, as manual deployments, rollbacks and the deploy command may.
+func deploysCommit(trigger provider.Trigger) bool {
+	return trigger.Type != provider.TagTrigger && trigger.Ref != trigger.Branch
+}
@@ -0,0 +1,210 @@
+package web_hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/logger"
+	"prolific/provider"
+	"prolific/queue"
+	"time"
+)
+
+// processJob returns the queue handler deploying the events of a provider.
+func processJob(p provider.Provider) queue.Handler {
+	return func(ctx context.Context, job *queue.Job) error {
+		var event provider.Event
+		err := json.Unmarshal(job.Payload, &event)
+		if err != nil {
+			return err
+		}
+		if event.Trigger.Owner == "" || event.Trigger.Repository == "" || event.Trigger.Branch == "" {
+			return errors.New("job " + job.ID + " does not describe a deployment")
+		}
+		if err := event.Trigger.Validate(); err != nil {
+			return errors.New("job " + job.ID + " refused: " + err.Error())
+		}
+		event.ID = job.ID
+		ctx = logger.NewContext(ctx, eventLogger(p, &event).With("deployment", job.ID))
+		stream := common.OpenStream(p.LogType(), job.ID)
+		log := runDeployment(common.NewStreamContext(ctx, stream), p, &event)
+		common.WriteLog(p.LogType(), log)
+		stream.Close(common.StreamEnd{ Success: log.Success, Interrupted: log.Interrupted, Error: log.Error })
+		return nil
+	}
+}
+
+// runDeployment announces, runs and reports the deployment of an event through its
+// provider, and returns the deployment log.
+func runDeployment(ctx context.Context, p provider.Provider, event *provider.Event) common.Log {
+
+	trigger := event.Trigger
+	owner := trigger.Owner
+	repository := trigger.Repository
+	branch := trigger.Branch
+	repositoryUrl := trigger.RepositoryUrl
+
+	deploymentLogger := logger.FromContext(ctx)
+
+	log := common.Log{
+		ID: event.ID,
+		Data: &common.LogData{
+			Owner: owner,
+			Repository: repository,
+			Branch: branch,
+			Trigger: trigger.Type,
+			Ref: trigger.Ref,
+			CommitSha: event.CommitSha,
+			TriggeredBy: event.Actor,
+			Reason: event.Reason,
+			RollbackOf: event.RollbackOf,
+			ApiResponses: []map[string]interface{}{},
+		},
+	}
+
+	comment := fmt.Sprintf("This %s has been **approved to [%s] stage** of [%s/%s](%s).\n",
+		trigger.Name, branch, owner, repository, repositoryUrl)
+	comment += "Prolific Deployment Tool will start the deployment process into the assigned server."
+	apiResponse, err := p.PostComment(event, comment)
+	recordApiResponses(deploymentLogger, &log, err, apiResponse)
+	apiResponses, err := p.UpdateStatus(event, provider.StatusRunning, "Deployment started.")
+	recordApiResponses(deploymentLogger, &log, err, apiResponses...)
+
+	// Deployment Start
+	start := time.Now()
+	executablesLogs, release, commitSha, err := deploy(ctx, event)
+	elapsed := time.Since(start)
+	end := start.Add(elapsed)
+	// Deployment Ended
+
+	log.Success = err == nil
+	log.StartedAt = start.Format(time.RFC1123)
+	log.EndedAt = end.Format(time.RFC1123)
+	log.TimeElapsed = elapsed.String()
+	log.Data.ExecutableLogs = executablesLogs
+	if commitSha != "" {
+		log.Data.CommitSha = commitSha
+	}
+	if release != nil {
+		log.Data.CommitSha = release.CommitSha
+		log.Data.Release = release.Name
+	}
+
+	status := provider.StatusSuccess
+	description := "Deployment succeeded."
+
+	if err == ErrDeploymentInterrupted {
+
+		// Deployment Interrupted
+		comment = fmt.Sprintf("**INTERRUPTED**: deployment of [%s] stage of [%s/%s](%s) was interrupted because Prolific was shut down. ",
+			branch, owner, repository, repositoryUrl)
+		comment += fmt.Sprintf("Manual review on the assigned server might be required.\n\n")
+		comment += fmt.Sprintf("| _Key_ | _Value_ |\n|---|---|\n")
+		comment += fmt.Sprintf("| Start Time | %s |\n", log.StartedAt)
+		comment += fmt.Sprintf("| Interrupted Time | %s |\n", log.EndedAt)
+		comment += fmt.Sprintf("| Elapsed Time | %s |\n", log.TimeElapsed)
+		log.Interrupted = true
+		log.Error = err.Error()
+		status = provider.StatusInterrupted
+		description = "Deployment interrupted by server shutdown."
+
+	} else if err == nil {
+
+		// Deployment Success
+		comment = fmt.Sprintf("**SUCCESS**: [%s] stage of [%s/%s](%s) has been deployed. \n\n",
+			branch, owner, repository, repositoryUrl)
+		comment += fmt.Sprintf("| _Key_ | _Value_ |\n|---|---|\n")
+		comment += fmt.Sprintf("| Start Time | %s |\n", log.StartedAt)
+		comment += fmt.Sprintf("| Finish Time | %s |\n", log.EndedAt)
+		comment += fmt.Sprintf("| Elapsed Time | %s |\n", log.TimeElapsed)
+		comment += healthCheckRows(executablesLogs)
+
+	} else {
+
+		hideErrorReason := config.GetWithDefault(p.Name(), "Hide_Error_Reason", "true") == "true"
+
+		// Deployment Failed
+		comment = fmt.Sprintf("**ERROR**: [%s] stage of [%s/%s](%s) failed to be deployed. ",
+			branch, owner, repository, repositoryUrl)
+		comment += fmt.Sprintf("Manual review on the assigned server might be required.\n\n")
+		if !hideErrorReason {
+			comment += fmt.Sprintf("Reason: `%s`\n\n", err.Error())
+		}
+		comment += fmt.Sprintf("| _Key_ | _Value_ |\n|---|---|\n")
+		comment += fmt.Sprintf("| Start Time | %s |\n", log.StartedAt)
+		comment += fmt.Sprintf("| Finish Time | %s |\n", log.EndedAt)
+		comment += fmt.Sprintf("| Elapsed Time | %s |\n", log.TimeElapsed)
+		comment += healthCheckRows(executablesLogs)
+		var healthCheckError *HealthCheckError
+		if errors.As(err, &healthCheckError) {
+			comment += fmt.Sprintf("| Rollback | %s |\n", queueAutomaticRollback(deploymentLogger, p, event))
+		}
+		log.Error = err.Error()
+		status = provider.StatusFailure
+		description = "Deployment failed."
+
+	}
+
+	apiResponse, err = p.PostComment(event, comment)
+	recordApiResponses(deploymentLogger, &log, err, apiResponse)
+	apiResponses, err = p.UpdateStatus(event, status, description)
+	recordApiResponses(deploymentLogger, &log, err, apiResponses...)
+
+	return log
+
+}
+
+// healthCheckRows lists the results of the health checks in the comment table.
+func healthCheckRows(executableLogs []common.ExecutableLog) string {
+	rows := ""
+	for _, executableLog := range executableLogs {
+		if executableLog.Step != HealthCheckStep {
+			continue
+		}
+		result := "Passed"
+		if executableLog.Error != "" {
+			result = fmt.Sprintf("Failed (`%s`)", executableLog.Error)
+		}
+		rows += fmt.Sprintf("| Health Check %s | %s |\n", executableLog.Name, result)
+	}
+	return rows
+}
+
+// queueAutomaticRollback queues the rollback of a deployment whose health checks failed
+// to the deployment live before it, unless PROLIFIC_AUTO_ROLLBACK is disabled or the
+// deployment was a rollback already, and describes the outcome.
+func queueAutomaticRollback(deploymentLogger *logger.Logger, p provider.Provider, event *provider.Event) string {
+	if config.GetWithDefault("Prolific", "Auto_Rollback", "true") != "true" {
+		return "Disabled"
+	}
+	if event.Trigger.Type == provider.RollbackTrigger {
+		return "Not attempted, the deployment was a rollback"
+	}
+	rollback, err := restoreEvent(p, *event)
+	if err != nil {
+		return "No previous deployment to roll back to"
+	}
+	trigger := event.Trigger
+	job, err := queue.Enqueue(p.Name(), jobKey(trigger.Repository, trigger.Branch), rollback)
+	if err != nil {
+		deploymentLogger.Error("Automatic rollback could not be queued", "error", err)
+		return "Failed to queue"
+	}
+	deploymentLogger.Info("Automatic rollback queued", "rollback", job.ID, "commit", rollback.CommitSha)
+	return fmt.Sprintf("Queued as `%s`, to `%s`", job.ID, rollback.CommitSha)
+}
+
+// recordApiResponses keeps the responses of provider API calls in the log.
+func recordApiResponses(deploymentLogger *logger.Logger, log *common.Log, err error, apiResponses ...map[string]interface{}) {
+	if err != nil {
+		deploymentLogger.Error("Provider API request failed", "error", err)
+	}
+	for _, apiResponse := range apiResponses {
+		if apiResponse != nil {
+			log.Data.ApiResponses = append(log.Data.ApiResponses, apiResponse)
+		}
+	}
+}
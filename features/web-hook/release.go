@@ -0,0 +1,214 @@
+package web_hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/logger"
+	"prolific/provider"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceDirName		= "repository"
+	ReleasesDirName		= "releases"
+	CurrentLinkName		= "current"
+	releaseTimeLayout	= "20060102150405"
+)
+
+// Release is a working tree of a single commit, built and deployed from its own
+// directory under "releases", next to the "repository" clone it is cloned from.
+type Release struct {
+	Name		string
+	Path		string
+	CommitSha	string
+}
+
+// releasesEnabled reports whether deployments use the releases layout instead of
+// updating the repository's working tree in place.
+func releasesEnabled() bool {
+	return config.GetWithDefault("Prolific", "Releases", "false") == "true"
+}
+
+// keptReleases is the number of releases kept besides the current one.
+func keptReleases() int {
+	keep, err := strconv.Atoi(config.GetWithDefault("Prolific", "Keep_Releases", "5"))
+	if err != nil || keep < 1 {
+		return 1
+	}
+	return keep
+}
+
+// prepareRelease fetches the "repository" clone of repoPath and clones the commit of
+// the trigger into a new release directory.
+func prepareRelease(ctx context.Context, repoPath string, user string, trigger provider.Trigger) (*Release, []common.ExecutableLog, error) {
+	var executableLogs []common.ExecutableLog
+
+	sourcePath := filepath.Join(repoPath, SourceDirName)
+	if _, err := os.Stat(sourcePath); err != nil {
+		return nil, executableLogs, errors.New("repository path " + sourcePath + " does not exist")
+	}
+
+	steps := []PipelineStep{
+		{ Name: "fetch", Args: []string{ "git", "fetch", "--quiet", "--tags", "origin" } },
+		{ Name: "resolve", Args: []string{ "git", "rev-parse", "--verify", deployedRef(trigger) + "^{commit}" } },
+	}
+	for _, step := range steps {
+		executableLog, err := runStep(ctx, sourcePath, user, triggerEnv(trigger), step)
+		executableLogs = append(executableLogs, executableLog)
+		if err != nil {
+			return nil, executableLogs, stepError(ctx, step, err)
+		}
+	}
+
+	commitSha := strings.TrimSpace(executableLogs[len(executableLogs)-1].Stdout)
+	if !commitShaPattern.MatchString(commitSha) {
+		return nil, executableLogs, errors.New("commit of " + trigger.Ref + " could not be resolved")
+	}
+	name := newReleaseName(repoPath, time.Now(), commitSha)
+	release := &Release{
+		Name:      name,
+		Path:      filepath.Join(repoPath, ReleasesDirName, name),
+		CommitSha: commitSha,
+	}
+
+	steps = []PipelineStep{
+		{ Name: "releases", Args: []string{ "mkdir", "-p", filepath.Dir(release.Path) } },
+		{ Name: "clone", Args: []string{ "git", "clone", "--quiet", "--no-checkout", ".", release.Path } },
+		{ Name: "checkout", Args: []string{ "git", "-C", release.Path, "checkout", "--quiet", "--detach", commitSha } },
+	}
+	for _, step := range steps {
+		executableLog, err := runStep(ctx, sourcePath, user, triggerEnv(trigger), step)
+		executableLogs = append(executableLogs, executableLog)
+		if err != nil {
+			return nil, executableLogs, stepError(ctx, step, err)
+		}
+	}
+
+	logger.FromContext(ctx).Info("Release prepared", "release", release.Name, "path", release.Path)
+	return release, executableLogs, nil
+}
+
+// deployedRef is the ref the trigger deploys, in a clone fetched from origin.
+func deployedRef(trigger provider.Trigger) string {
+	switch {
+	case trigger.Type == provider.TagTrigger:
+		return "refs/tags/" + trigger.Ref
+	case deploysCommit(trigger):
+		return trigger.Ref
+	}
+	return "refs/remotes/origin/" + trigger.Ref
+}
+
+// env describes the release to the pipeline steps through environment variables.
+func (release *Release) env() []string {
+	return []string{
+		"PROLIFIC_RELEASE=" + release.Name,
+		"PROLIFIC_RELEASE_PATH=" + release.Path,
+		"PROLIFIC_COMMIT_SHA=" + release.CommitSha,
+	}
+}
+
+// activate atomically points the "current" link of repoPath to the release.
+func (release *Release) activate(repoPath string) common.ExecutableLog {
+	linkPath := filepath.Join(repoPath, CurrentLinkName)
+	target := filepath.Join(ReleasesDirName, release.Name)
+	executableLog := common.ExecutableLog{
+		Step:    "activate",
+		Name:    "symlink",
+		Args:    fmt.Sprintf("%s -> %s", CurrentLinkName, target),
+		WorkDir: repoPath,
+	}
+
+	temporaryLinkPath := linkPath + ".tmp"
+	_ = os.Remove(temporaryLinkPath)
+	err := os.Symlink(target, temporaryLinkPath)
+	if err == nil {
+		err = os.Rename(temporaryLinkPath, linkPath)
+	}
+	if err != nil {
+		_ = os.Remove(temporaryLinkPath)
+		executableLog.Error = err.Error()
+	}
+	return executableLog
+}
+
+// currentRelease returns the name of the release the "current" link of repoPath points
+// to, or an empty string when there is none.
+func currentRelease(repoPath string) string {
+	target, err := os.Readlink(filepath.Join(repoPath, CurrentLinkName))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// newReleaseName names a release of commitSha prepared at the given time. Names have
+// a one-second resolution, so a release of the same commit prepared within the same
+// second gets a numbered suffix instead of being cloned into the existing directory.
+func newReleaseName(repoPath string, at time.Time, commitSha string) string {
+	name := fmt.Sprintf("%s-%s", at.UTC().Format(releaseTimeLayout), commitSha[:7])
+	unique := name
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(repoPath, ReleasesDirName, unique)); err != nil {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+func releaseExists(repoPath string, name string) bool {
+	if filepath.Base(name) != name {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(repoPath, ReleasesDirName, name))
+	return err == nil && info.IsDir()
+}
+
+// listReleases returns the names of the releases of repoPath, oldest first.
+func listReleases(repoPath string) []string {
+	files, err := ioutil.ReadDir(filepath.Join(repoPath, ReleasesDirName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Releases could not be listed", "path", repoPath, "error", err)
+		}
+		return nil
+	}
+	var names []string
+	for _, file := range files {
+		if file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pruneReleases removes the oldest releases of repoPath, keeping the current one and
+// the given number of others.
+func pruneReleases(ctx context.Context, repoPath string, keep int) {
+	current := currentRelease(repoPath)
+	var others []string
+	for _, name := range listReleases(repoPath) {
+		if name != current {
+			others = append(others, name)
+		}
+	}
+	for len(others) > keep {
+		releasePath := filepath.Join(repoPath, ReleasesDirName, others[0])
+		if err := os.RemoveAll(releasePath); err != nil {
+			logger.FromContext(ctx).Error("Release could not be removed", "release", others[0], "error", err)
+		} else {
+			logger.FromContext(ctx).Info("Release removed", "release", others[0])
+		}
+		others = others[1:]
+	}
+}
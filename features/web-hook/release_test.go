@@ -0,0 +1,33 @@
+package web_hook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewReleaseNameIsUniqueWithinASecond(t *testing.T) {
+	repoPath, err := ioutil.TempDir("", "prolific-releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoPath)
+
+	at := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	commitSha := "0123456789abcdef0123456789abcdef01234567"
+	want := []string{ "20240301123045-0123456", "20240301123045-0123456-2", "20240301123045-0123456-3" }
+	for _, name := range want {
+		if got := newReleaseName(repoPath, at, commitSha); got != name {
+			t.Fatalf("newReleaseName() = %q, want %q", got, name)
+		}
+		if err := os.MkdirAll(filepath.Join(repoPath, ReleasesDirName, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := newReleaseName(repoPath, at.Add(time.Second), commitSha); got != "20240301123046-0123456" {
+		t.Errorf("newReleaseName() a second later = %q", got)
+	}
+}
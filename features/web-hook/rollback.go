@@ -0,0 +1,105 @@
+package web_hook
+
+import (
+	"errors"
+	"fmt"
+	"prolific/features/common"
+	"prolific/provider"
+)
+
+var ErrNothingToRollBack = errors.New("no previous successful deployment to roll back to")
+
+// liveDeployments returns the successful deployments of a stage that were not rolled
+// back, newest first. The first one is the deployment currently live.
+func liveDeployments(logType common.LogType, owner string, repository string, branch string) []*common.Log {
+	success := true
+	return notRolledBack(common.QueryLogs(logType, common.LogQuery{
+		Owner:      owner,
+		Repository: repository,
+		Branch:     branch,
+		Success:    &success,
+	}))
+}
+
+// notRolledBack returns the successful deployments of the logs, oldest first, that no
+// later successful rollback reverted, newest first. The deployment a rollback reverted
+// stays reverted once the rollback is rolled back too, as rolling back goes further back.
+func notRolledBack(logs common.Logs) []*common.Log {
+	rolledBack := map[string]bool{}
+	var deployments []*common.Log
+
+	for index := len(logs) - 1; index >= 0; index-- {
+		log := &logs[index]
+		if !log.Success || log.Data == nil {
+			continue
+		}
+		if log.Data.RollbackOf != "" {
+			rolledBack[log.Data.RollbackOf] = true
+		}
+		if rolledBack[log.ID] {
+			continue
+		}
+		deployments = append(deployments, log)
+	}
+	return deployments
+}
+
+// rollbackTarget returns the deployment of a stage to roll back, which is the one
+// currently live, and the earlier successful deployment of another commit to roll back
+// to.
+func rollbackTarget(logType common.LogType, owner string, repository string, branch string) (*common.Log, *common.Log, error) {
+	return rollbackTargetOf(liveDeployments(logType, owner, repository, branch))
+}
+
+// rollbackTargetOf returns the first of the live deployments, newest first, and the
+// next one of another commit.
+func rollbackTargetOf(deployments []*common.Log) (*common.Log, *common.Log, error) {
+	if len(deployments) == 0 {
+		return nil, nil, ErrNothingToRollBack
+	}
+	reverted := deployments[0]
+	for _, log := range deployments[1:] {
+		if log.Data.CommitSha != "" && log.Data.CommitSha != reverted.Data.CommitSha {
+			return reverted, log, nil
+		}
+	}
+	return nil, nil, ErrNothingToRollBack
+}
+
+// rollbackEvent turns an event of a stage into the rollback of its live deployment,
+// reported wherever the event would have been.
+func rollbackEvent(p provider.Provider, event provider.Event) (*provider.Event, error) {
+	trigger := event.Trigger
+	reverted, target, err := rollbackTarget(p.LogType(), trigger.Owner, trigger.Repository, trigger.Branch)
+	if err != nil {
+		return nil, err
+	}
+	return revertTo(event, reverted.ID, target), nil
+}
+
+// restoreEvent turns the event of a failed deployment into the rollback of that
+// deployment to the deployment that was live before it.
+func restoreEvent(p provider.Provider, event provider.Event) (*provider.Event, error) {
+	trigger := event.Trigger
+	deployments := liveDeployments(p.LogType(), trigger.Owner, trigger.Repository, trigger.Branch)
+	if len(deployments) == 0 || deployments[0].Data.CommitSha == "" {
+		return nil, ErrNothingToRollBack
+	}
+	return revertTo(event, event.ID, deployments[0]), nil
+}
+
+func revertTo(event provider.Event, revertedID string, target *common.Log) *provider.Event {
+	metadata := map[string]string{}
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	event.ID = ""
+	event.Metadata = metadata
+	event.Trigger.Type = provider.RollbackTrigger
+	event.Trigger.Ref = target.Data.CommitSha
+	event.Trigger.Name = fmt.Sprintf("rollback of deployment `%s`", revertedID)
+	event.CommitSha = target.Data.CommitSha
+	event.RollbackOf = revertedID
+	event.Release = target.Data.Release
+	return &event
+}
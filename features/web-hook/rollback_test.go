@@ -0,0 +1,100 @@
+package web_hook
+
+import (
+	"prolific/features/common"
+	"testing"
+)
+
+// deployment is the log of a deployment of a stage, which failed when it has no commit
+// SHA but "!".
+func deployment(id string, commitSha string, rollbackOf string) common.Log {
+	success := commitSha != "!"
+	if !success {
+		commitSha = "c0ffee"
+	}
+	return common.Log{
+		ID:      id,
+		Success: success,
+		Data:    &common.LogData{ Owner: "acme", Repository: "shop", Branch: "main", CommitSha: commitSha, RollbackOf: rollbackOf },
+	}
+}
+
+func TestRollbackTarget(t *testing.T) {
+	tests := []struct {
+		name	string
+		logs	common.Logs
+		want	string
+	}{
+		{ "no deployment", nil, "none" },
+		{ "single deployment", common.Logs{ deployment("d1", "aaa", "") }, "none" },
+		{ "previous deployment", common.Logs{
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+		}, "d2 to d1" },
+		{ "failed latest deployment", common.Logs{
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+			deployment("d3", "!", ""),
+		}, "d2 to d1" },
+		{ "same commit deployed again", common.Logs{
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+			deployment("d3", "bbb", ""),
+		}, "d3 to d1" },
+		{ "missing SHA", common.Logs{
+			deployment("d1", "aaa", ""),
+			deployment("d2", "", ""),
+			deployment("d3", "bbb", ""),
+		}, "d3 to d1" },
+		{ "only missing SHAs", common.Logs{
+			deployment("d1", "", ""),
+			deployment("d2", "bbb", ""),
+		}, "none" },
+		{ "rollback", common.Logs{
+			deployment("d0", "zzz", ""),
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+			deployment("r1", "aaa", "d2"),
+		}, "r1 to d0" },
+		{ "rollback of a rollback", common.Logs{
+			deployment("d0", "zzz", ""),
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+			deployment("r1", "aaa", "d2"),
+			deployment("r2", "zzz", "r1"),
+		}, "r2 to d1" },
+		{ "failed rollback", common.Logs{
+			deployment("d1", "aaa", ""),
+			deployment("d2", "bbb", ""),
+			deployment("r1", "!", "d2"),
+		}, "d2 to d1" },
+	}
+	for _, test := range tests {
+		got := "none"
+		reverted, target, err := rollbackTargetOf(notRolledBack(test.logs))
+		if err == nil {
+			got = reverted.ID + " to " + target.ID
+		} else if err != ErrNothingToRollBack {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestLiveDeploymentsSkipRolledBackOnes(t *testing.T) {
+	logs := common.Logs{
+		deployment("d1", "aaa", ""),
+		deployment("d2", "bbb", ""),
+		deployment("d3", "!", ""),
+		deployment("r1", "aaa", "d2"),
+	}
+	var ids []string
+	for _, log := range notRolledBack(logs) {
+		ids = append(ids, log.ID)
+	}
+	if len(ids) != 2 || ids[0] != "r1" || ids[1] != "d1" {
+		t.Fatalf("expected r1 then d1 to be live, got %v", ids)
+	}
+}
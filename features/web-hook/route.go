@@ -3,14 +3,40 @@ package web_hook
 import (
 	"github.com/gorilla/mux"
 	"net/http"
+	"prolific/provider"
+	"prolific/queue"
 )
 
-func New() Route {
-	return Route{}
+func New(providers ...provider.Provider) Route {
+	for _, p := range providers {
+		queue.Register(p.Name(), processJob(p))
+	}
+	return Route{ providers }
 }
 
-type Route struct {}
+type Route struct {
+	providers	[]provider.Provider
+}
 
 func (route Route) Initialise(r *mux.Router) {
-	r.Path("/github").Methods(http.MethodPost).HandlerFunc(github)
-}
\ No newline at end of file
+	for _, p := range route.providers {
+		r.Path("/" + p.Name()).Methods(http.MethodPost).HandlerFunc(webHook(p))
+	}
+}
+// NewDeploy creates the route of manual deployments, logged and reported through the
+// providers.
+func NewDeploy(providers ...provider.Provider) DeployRoute {
+	return DeployRoute{ providers }
+}
+
+type DeployRoute struct {
+	providers	[]provider.Provider
+}
+
+// Initialise registers the routes of <owner>/<repository>/<branch>, whose owner and
+// branch may hold slashes. Rollbacks are registered first, so that a trailing /rollback
+// is never read as part of the branch.
+func (route DeployRoute) Initialise(r *mux.Router) {
+	r.Path("/{target:.+}/rollback").Methods(http.MethodPost).HandlerFunc(manualRollback(route.providers))
+	r.Path("/{target:.+}").Methods(http.MethodPost).HandlerFunc(manualDeploy(route.providers))
+}
@@ -0,0 +1,121 @@
+package web_hook
+
+import (
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path"
+	"prolific/config"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+const TagCreateEvent = "create"
+
+// BranchTriggerRule deploys events targeting one of the branches, or any watched branch
+// when no branch is listed.
+type BranchTriggerRule struct {
+	Branches	[]string	`yaml:"branches"`
+}
+
+// TagTriggerRule deploys tags matching the pattern into the stage directory, on the
+// forge event given, which is "create" by default.
+type TagTriggerRule struct {
+	Pattern	string	`yaml:"pattern"`
+	Stage	string	`yaml:"stage"`
+	Event	string	`yaml:"event"`
+}
+
+// TriggerRules are the events deploying a repository, as configured in the triggers
+// file under "<owner>/<repository>".
+type TriggerRules struct {
+	PullRequest	*BranchTriggerRule	`yaml:"pull_request"`
+	Push		*BranchTriggerRule	`yaml:"push"`
+	Tag			*TagTriggerRule		`yaml:"tag"`
+}
+
+// defaultTriggerRules only deploys merged pull requests, as Prolific always did.
+func defaultTriggerRules() TriggerRules {
+	return TriggerRules{ PullRequest: &BranchTriggerRule{} }
+}
+
+// triggerRules returns the trigger rules of a repository, read from the file named by
+// PROLIFIC_TRIGGERS_FILE. Repositories not listed there use the default rules.
+func triggerRules(owner string, repository string) TriggerRules {
+	triggersFilePath := config.GetWithDefault("Prolific", "Triggers_File", "triggers.yml")
+	content, err := ioutil.ReadFile(triggersFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Trigger rules could not be read", "file", triggersFilePath, "error", err)
+		}
+		return defaultTriggerRules()
+	}
+	var rules map[string]TriggerRules
+	err = yaml.Unmarshal(content, &rules)
+	if err != nil {
+		logger.Error("Trigger rules could not be parsed", "file", triggersFilePath, "error", err)
+		return defaultTriggerRules()
+	}
+	repositoryRules, ok := rules[owner + "/" + repository]
+	if !ok {
+		return defaultTriggerRules()
+	}
+	return repositoryRules
+}
+
+// applyTriggerRules reports whether the repository's trigger rules deploy the trigger,
+// assigning the stage of tags.
+func applyTriggerRules(trigger *provider.Trigger) bool {
+	rules := triggerRules(trigger.Owner, trigger.Repository)
+	switch trigger.Type {
+	case provider.PullRequestTrigger:
+		return rules.PullRequest.matches(trigger.Branch)
+	case provider.PushTrigger:
+		return rules.Push.matches(trigger.Branch)
+	case provider.TagTrigger:
+		if !rules.Tag.matches(trigger.Event, trigger.Ref) {
+			return false
+		}
+		trigger.Branch = rules.Tag.Stage
+		return true
+	}
+	return false
+}
+
+func (rule *BranchTriggerRule) matches(branch string) bool {
+	if rule == nil {
+		return false
+	}
+	if len(rule.Branches) == 0 {
+		return true
+	}
+	for _, b := range rule.Branches {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *TagTriggerRule) matches(event string, tag string) bool {
+	if rule == nil || rule.Stage == "" {
+		return false
+	}
+	ruleEvent := rule.Event
+	if ruleEvent == "" {
+		ruleEvent = TagCreateEvent
+	}
+	if ruleEvent != event {
+		return false
+	}
+	pattern := rule.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	matched, err := path.Match(pattern, tag)
+	if err != nil {
+		logger.Warn("Invalid tag pattern", "pattern", pattern, "error", err)
+		return false
+	}
+	return matched
+}
@@ -1,7 +1,10 @@
 package web_hook
 
 import (
+	"fmt"
 	"prolific/config"
+	"prolific/features/common"
+	"prolific/provider"
 	"strings"
 )
 
@@ -19,4 +22,38 @@ func isWatched(element string, elementKey string) bool {
 		}
 	}
 	return false
+}
+
+// checkWatched returns the error to respond with when the owner, repository or branch
+// is not being watched, or nil when all of them are.
+func checkWatched(owner string, repository string, branch string) *common.Error {
+	if !isWatched(owner, OwnerElementKey) {
+		reason := fmt.Sprintf("Owner %s is not being watched.", owner)
+		return common.CreateError(1001, reason)
+	}
+	if !isWatched(repository, RepositoryElementKey) {
+		reason := fmt.Sprintf("Repository %s is not being watched.", repository)
+		return common.CreateError(1002, reason)
+	}
+	if !isWatched(branch, BranchElementKey) {
+		reason := fmt.Sprintf("Branch %s is not being watched.", branch)
+		return common.CreateError(1003, reason)
+	}
+	return nil
+}
+
+// checkTrigger returns the error to respond with when the names of the trigger are not
+// valid, or nil when they are.
+func checkTrigger(trigger provider.Trigger) *common.Error {
+	if err := trigger.Validate(); err != nil {
+		return common.CreateError(1004, fmt.Sprintf("Trigger refused, %s.", err.Error()))
+	}
+	return nil
+}
+
+// jobKey identifies the working tree a deployment runs against, <branch>/<repository>
+// under PROLIFIC_ROOT_PATH, so that deployments sharing it are never run at the same
+// time. Repositories of the same name share it whatever their owner.
+func jobKey(repository string, branch string) string {
+	return fmt.Sprintf("%s/%s", branch, repository)
 }
\ No newline at end of file
@@ -5,4 +5,7 @@ go 1.14
 require (
 	github.com/gorilla/mux v1.7.4
 	github.com/with-go/config v1.0.1
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/sys v0.10.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"errors"
+	"prolific/config"
+	"strconv"
+)
+
+// Configure sets the level, format and output of the entries from PROLIFIC_LOG_LEVEL,
+// debug, info, warn or error, PROLIFIC_LOG_FORMAT, logfmt or json, and
+// PROLIFIC_LOG_OUTPUT, stdout, stderr or file. The file output writes to
+// PROLIFIC_LOG_FILE, rotated once it reaches PROLIFIC_LOG_FILE_MAX_SIZE megabytes,
+// keeping PROLIFIC_LOG_FILE_MAX_BACKUPS gzipped rotated files.
+func Configure() error {
+	newLevel, ok := ParseLevel(config.GetWithDefault("Prolific", "Log_Level", "info"))
+	if !ok {
+		return errors.New("unknown log level " + config.Get("Prolific", "Log_Level"))
+	}
+
+	var newFormat Formatter
+	switch name := config.GetWithDefault("Prolific", "Log_Format", "logfmt"); name {
+	case "logfmt":
+		newFormat = logfmtFormatter{}
+	case "json":
+		newFormat = jsonFormatter{}
+	default:
+		return errors.New("unknown log format " + name)
+	}
+
+	var newOutput Output
+	switch name := config.GetWithDefault("Prolific", "Log_Output", "stdout"); name {
+	case "stdout":
+		newOutput = stdout
+	case "stderr":
+		newOutput = stderr
+	case "file":
+		maxSize, err := strconv.ParseInt(config.GetWithDefault("Prolific", "Log_File_Max_Size", "100"), 10, 64)
+		if err != nil || maxSize < 0 {
+			return errors.New("invalid log file max size " + config.Get("Prolific", "Log_File_Max_Size"))
+		}
+		maxBackups, err := strconv.Atoi(config.GetWithDefault("Prolific", "Log_File_Max_Backups", "5"))
+		if err != nil || maxBackups < 0 {
+			return errors.New("invalid log file max backups " + config.Get("Prolific", "Log_File_Max_Backups"))
+		}
+		newOutput = &RotatingFile{
+			Path:       config.GetWithDefault("Prolific", "Log_File", "logs/prolific.log"),
+			MaxSize:    maxSize * 1024 * 1024,
+			MaxBackups: maxBackups,
+		}
+	default:
+		return errors.New("unknown log output " + name)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err := output.Close(); err != nil {
+		return err
+	}
+	level, format, output = newLevel, newFormat, newOutput
+	return nil
+}
+
+// Close closes the output, such as the log file.
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return output.Close()
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a log entry. Fields alternate keys and values.
+type Entry struct {
+	Time	time.Time
+	Level	Level
+	Message	string
+	Fields	[]interface{}
+}
+
+// Formatter renders an entry as a line.
+type Formatter interface {
+	Format(entry Entry) []byte
+}
+
+// pairs returns the keys and values of the fields, under "field" for a value without a
+// key.
+func (entry Entry) pairs() ([]string, []interface{}) {
+	var keys []string
+	var values []interface{}
+	for index := 0; index < len(entry.Fields); index += 2 {
+		if index + 1 == len(entry.Fields) {
+			keys = append(keys, "field")
+			values = append(values, entry.Fields[index])
+			break
+		}
+		keys = append(keys, fmt.Sprint(entry.Fields[index]))
+		values = append(values, entry.Fields[index+1])
+	}
+	return keys, values
+}
+
+// logfmtFormatter renders entries as key=value pairs, quoting values when needed.
+type logfmtFormatter struct{}
+
+func (logfmtFormatter) Format(entry Entry) []byte {
+	var line bytes.Buffer
+	line.WriteString("time=" + entry.Time.UTC().Format(time.RFC3339))
+	line.WriteString(" level=" + entry.Level.String())
+	line.WriteString(" msg=" + logfmtValue(entry.Message))
+	keys, values := entry.pairs()
+	for index, key := range keys {
+		line.WriteString(" " + key + "=" + logfmtValue(stringValue(values[index])))
+	}
+	line.WriteByte('\n')
+	return line.Bytes()
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\\\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// jsonFormatter renders entries as JSON objects, keeping the order of the fields.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(entry Entry) []byte {
+	var line bytes.Buffer
+	line.WriteString(`{"time":` + jsonValue(entry.Time.UTC().Format(time.RFC3339)))
+	line.WriteString(`,"level":` + jsonValue(entry.Level.String()))
+	line.WriteString(`,"msg":` + jsonValue(entry.Message))
+	keys, values := entry.pairs()
+	for index, key := range keys {
+		value := values[index]
+		switch value.(type) {
+		case error, fmt.Stringer:
+			value = stringValue(value)
+		}
+		line.WriteString("," + jsonValue(key) + ":" + jsonValue(value))
+	}
+	line.WriteString("}\n")
+	return line.Bytes()
+}
+
+func jsonValue(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return string(content)
+}
+
+func stringValue(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case error:
+		return typed.Error()
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (level Level) String() string {
+	return levelNames[level]
+}
+
+// ParseLevel returns the level named debug, info, warn or error.
+func ParseLevel(name string) (Level, bool) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, true
+		}
+	}
+	return InfoLevel, false
+}
+
+// Logger writes entries carrying its fields, given as alternating keys and values, in
+// addition to those of each entry.
+type Logger struct {
+	fields	[]interface{}
+}
+
+type contextKey struct{}
+
+var (
+	mutex		sync.Mutex
+	level		= InfoLevel
+	format		Formatter = logfmtFormatter{}
+	output		Output = stdout
+	root		= &Logger{}
+)
+
+// With returns a logger carrying the fields.
+func With(keyValues ...interface{}) *Logger {
+	return root.With(keyValues...)
+}
+
+// With returns a copy of the logger carrying the fields in addition to its own.
+func (logger *Logger) With(keyValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(logger.fields) + len(keyValues))
+	fields = append(append(fields, logger.fields...), keyValues...)
+	return &Logger{ fields: fields }
+}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the logger without fields.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return logger
+	}
+	return root
+}
+
+func (logger *Logger) Debug(message string, keyValues ...interface{}) {
+	logger.log(DebugLevel, message, keyValues)
+}
+
+func (logger *Logger) Info(message string, keyValues ...interface{}) {
+	logger.log(InfoLevel, message, keyValues)
+}
+
+func (logger *Logger) Warn(message string, keyValues ...interface{}) {
+	logger.log(WarnLevel, message, keyValues)
+}
+
+func (logger *Logger) Error(message string, keyValues ...interface{}) {
+	logger.log(ErrorLevel, message, keyValues)
+}
+
+func Debug(message string, keyValues ...interface{}) {
+	root.log(DebugLevel, message, keyValues)
+}
+
+func Info(message string, keyValues ...interface{}) {
+	root.log(InfoLevel, message, keyValues)
+}
+
+func Warn(message string, keyValues ...interface{}) {
+	root.log(WarnLevel, message, keyValues)
+}
+
+func Error(message string, keyValues ...interface{}) {
+	root.log(ErrorLevel, message, keyValues)
+}
+
+// Fatal writes an error entry and exits.
+func Fatal(message string, keyValues ...interface{}) {
+	root.log(ErrorLevel, message, keyValues)
+	mutex.Lock()
+	_ = output.Close()
+	mutex.Unlock()
+	os.Exit(1)
+}
+
+func (logger *Logger) log(entryLevel Level, message string, keyValues []interface{}) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if entryLevel < level {
+		return
+	}
+	fields := append(append([]interface{}{}, logger.fields...), keyValues...)
+	line := format.Format(Entry{ Time: time.Now(), Level: entryLevel, Message: message, Fields: fields })
+	if _, err := output.Write(line); err != nil {
+		_, _ = os.Stderr.Write(line)
+	}
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Output receives the lines of the entries.
+type Output interface {
+	io.Writer
+	Close() error
+}
+
+type standardOutput struct {
+	*os.File
+}
+
+// Close leaves the standard streams open.
+func (standardOutput) Close() error {
+	return nil
+}
+
+var (
+	stdout	= standardOutput{ os.Stdout }
+	stderr	= standardOutput{ os.Stderr }
+)
+
+// RotatingFile is an output appending to a file, which is rotated once it would exceed
+// MaxSize bytes: the file is gzipped to <path>.1.gz, the previous <path>.1.gz becomes
+// <path>.2.gz, and so on, keeping at most MaxBackups rotated files. Files are gzipped in
+// the background: a write only waits for it when it rotates the file again before the
+// previous rotated file is gzipped, as the backups are not renamed while it is.
+type RotatingFile struct {
+	Path		string
+	MaxSize		int64
+	MaxBackups	int
+	file		*os.File
+	size		int64
+	compressing	sync.WaitGroup
+}
+
+func (output *RotatingFile) Write(line []byte) (int, error) {
+	if output.file == nil {
+		if err := output.open(); err != nil {
+			return 0, err
+		}
+	}
+	if output.MaxSize > 0 && output.size > 0 && output.size + int64(len(line)) > output.MaxSize {
+		if err := output.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	written, err := output.file.Write(line)
+	output.size += int64(written)
+	return written, err
+}
+
+func (output *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(output.Path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(output.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	output.file = file
+	output.size = info.Size()
+	return nil
+}
+
+func (output *RotatingFile) rotate() error {
+	if err := output.Close(); err != nil {
+		return err
+	}
+	if output.MaxBackups < 1 {
+		if err := os.Remove(output.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return output.open()
+	}
+	_ = os.Remove(output.backupPath(output.MaxBackups))
+	for index := output.MaxBackups - 1; index >= 1; index-- {
+		err := os.Rename(output.backupPath(index), output.backupPath(index + 1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	rotatedPath := output.Path + ".1"
+	if err := os.Rename(output.Path, rotatedPath); err != nil {
+		if os.IsNotExist(err) {
+			return output.open()
+		}
+		return err
+	}
+	output.compressing.Add(1)
+	go func() {
+		defer output.compressing.Done()
+		// The logger cannot report its own errors while its output is being closed.
+		if err := gzipFile(rotatedPath, output.backupPath(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "log file %s could not be gzipped: %v\n", rotatedPath, err)
+		}
+	}()
+	return output.open()
+}
+
+func (output *RotatingFile) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d.gz", output.Path, index)
+}
+
+// Close closes the file, once the rotated files are gzipped.
+func (output *RotatingFile) Close() error {
+	output.compressing.Wait()
+	if output.file == nil {
+		return nil
+	}
+	err := output.file.Close()
+	output.file = nil
+	return err
+}
+
+// gzipFile gzips the file at path to archivePath, and removes it once archived.
+func gzipFile(path string, archivePath string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	temporaryPath := archivePath + ".tmp"
+	archive, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(archive)
+	_, err = io.Copy(writer, source)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporaryPath, archivePath)
+	}
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,202 @@
+package bitbucket
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/provider"
+	"strings"
+)
+
+const (
+	CloudMergedEvent	= "pullrequest:fulfilled"
+	ServerMergedEvent	= "pr:merged"
+	CloudFlavor			= "cloud"
+	ServerFlavor		= "server"
+)
+
+var LogType common.LogType = "Bitbucket"
+
+type CloudPayloadBranch struct {
+	Name	string	`json:"name"`
+}
+
+type CloudPayloadDestination struct {
+	Branch	CloudPayloadBranch	`json:"branch"`
+}
+
+type CloudPayloadCommit struct {
+	Hash	string	`json:"hash"`
+}
+
+type CloudPayloadPullRequest struct {
+	ID			int						`json:"id"`
+	Destination	CloudPayloadDestination	`json:"destination"`
+	MergeCommit	CloudPayloadCommit		`json:"merge_commit"`
+}
+
+type CloudPayloadLink struct {
+	Href	string	`json:"href"`
+}
+
+type CloudPayloadLinks struct {
+	Html	CloudPayloadLink	`json:"html"`
+}
+
+type CloudPayloadRepository struct {
+	FullName	string				`json:"full_name"`
+	Links		CloudPayloadLinks	`json:"links"`
+}
+
+type CloudWebHookPayload struct {
+	PullRequest	CloudPayloadPullRequest	`json:"pullrequest"`
+	Repository	CloudPayloadRepository	`json:"repository"`
+}
+
+type ServerPayloadProject struct {
+	Key	string	`json:"key"`
+}
+
+type ServerPayloadRepository struct {
+	Slug	string					`json:"slug"`
+	Project	ServerPayloadProject	`json:"project"`
+}
+
+type ServerPayloadRef struct {
+	DisplayID	string					`json:"displayId"`
+	Repository	ServerPayloadRepository	`json:"repository"`
+}
+
+type ServerPayloadCommit struct {
+	ID	string	`json:"id"`
+}
+
+type ServerPayloadProperties struct {
+	MergeCommit	ServerPayloadCommit	`json:"mergeCommit"`
+}
+
+type ServerPayloadPullRequest struct {
+	ID			int						`json:"id"`
+	ToRef		ServerPayloadRef		`json:"toRef"`
+	Properties	ServerPayloadProperties	`json:"properties"`
+}
+
+type ServerWebHookPayload struct {
+	PullRequest	ServerPayloadPullRequest	`json:"pullRequest"`
+}
+
+// Provider handles both Bitbucket Cloud and Bitbucket Server, telling them apart by
+// their event keys. The flavor of an event is kept in its "flavor" metadata.
+type Provider struct {}
+
+func New() Provider {
+	return Provider{}
+}
+
+func (p Provider) Name() string {
+	return "bitbucket"
+}
+
+// RepositoryUrl links to Bitbucket Server when BITBUCKET_SERVER_URL is set, and to
+// Bitbucket Cloud otherwise.
+func (p Provider) RepositoryUrl(owner string, repository string) string {
+	if serverUrl() != "" {
+		return fmt.Sprintf("%s/projects/%s/repos/%s", serverUrl(), owner, repository)
+	}
+	return fmt.Sprintf("https://bitbucket.org/%s/%s", owner, repository)
+}
+
+func (p Provider) LogType() common.LogType {
+	return LogType
+}
+
+// Verify checks the "sha256=" prefixed HMAC sent in X-Hub-Signature by Bitbucket Server,
+// and by Bitbucket Cloud webhooks that have a secret.
+func (p Provider) Verify(header http.Header, body []byte) error {
+	signature := header.Get("X-Hub-Signature")
+	if signature == "" {
+		return provider.ErrNoSignature
+	}
+	if !strings.HasPrefix(signature, "sha256=") ||
+		!provider.IsSignedBy(sha256.New, provider.WebHookSecrets(p.Name()), body, strings.TrimPrefix(signature, "sha256=")) {
+		return provider.ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parse handles merged pull requests of both flavors, along with their merge commit.
+func (p Provider) Parse(header http.Header, body []byte) (*provider.Event, error) {
+	event := header.Get("X-Event-Key")
+	switch event {
+	case CloudMergedEvent:
+		var webHookPayload CloudWebHookPayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		fullName := strings.SplitN(webHookPayload.Repository.FullName, "/", 2)
+		if len(fullName) != 2 {
+			return nil, fmt.Errorf("repository full name %s is invalid", webHookPayload.Repository.FullName)
+		}
+		branch := webHookPayload.PullRequest.Destination.Branch.Name
+		return &provider.Event{
+			Trigger: provider.Trigger{
+				Type:          provider.PullRequestTrigger,
+				Event:         event,
+				Owner:         fullName[0],
+				Repository:    fullName[1],
+				Branch:        branch,
+				Ref:           branch,
+				RepositoryUrl: webHookPayload.Repository.Links.Html.Href,
+				Name:          fmt.Sprintf("PR #%d", webHookPayload.PullRequest.ID),
+			},
+			Number:    webHookPayload.PullRequest.ID,
+			CommitSha: webHookPayload.PullRequest.MergeCommit.Hash,
+			Metadata:  map[string]string{ "flavor": CloudFlavor },
+		}, nil
+	case ServerMergedEvent:
+		var webHookPayload ServerWebHookPayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		toRef := webHookPayload.PullRequest.ToRef
+		projectKey := toRef.Repository.Project.Key
+		slug := toRef.Repository.Slug
+		return &provider.Event{
+			Trigger: provider.Trigger{
+				Type:          provider.PullRequestTrigger,
+				Event:         event,
+				Owner:         projectKey,
+				Repository:    slug,
+				Branch:        toRef.DisplayID,
+				Ref:           toRef.DisplayID,
+				RepositoryUrl: fmt.Sprintf("%s/projects/%s/repos/%s", serverUrl(), projectKey, slug),
+				Name:          fmt.Sprintf("PR #%d", webHookPayload.PullRequest.ID),
+			},
+			Number:    webHookPayload.PullRequest.ID,
+			CommitSha: webHookPayload.PullRequest.Properties.MergeCommit.ID,
+			Metadata:  map[string]string{ "flavor": ServerFlavor },
+		}, nil
+	}
+	return nil, nil
+}
+
+// PostComment comments on the merged pull request.
+func (p Provider) PostComment(event *provider.Event, comment string) (map[string]interface{}, error) {
+	if event.Number == 0 {
+		return nil, nil
+	}
+	return createBitbucketComment(event, comment)
+}
+
+// UpdateStatus does nothing, progress on Bitbucket is only reported through comments.
+func (p Provider) UpdateStatus(event *provider.Event, status provider.Status, description string) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+// serverUrl returns the base URL of the configured Bitbucket Server instance.
+func serverUrl() string {
+	return strings.TrimSuffix(config.Get("bitbucket", "Server_Url"), "/")
+}
@@ -0,0 +1,62 @@
+package bitbucket
+
+import (
+	"net/http"
+	"testing"
+)
+
+const cloudMergedPayload = `{
+	"pullrequest": {
+		"id": 12,
+		"destination": { "branch": { "name": "main" } },
+		"merge_commit": { "hash": "0123456789ab" }
+	},
+	"repository": {
+		"full_name": "acme/shop",
+		"links": { "html": { "href": "https://bitbucket.org/acme/shop" } }
+	}
+}`
+
+const serverMergedPayload = `{
+	"pullRequest": {
+		"id": 12,
+		"toRef": {
+			"displayId": "main",
+			"repository": { "slug": "shop", "project": { "key": "ACME" } }
+		},
+		"properties": {
+			"mergeCommit": { "displayId": "0123456789a", "id": "0123456789abcdef0123456789abcdef01234567" }
+		}
+	}
+}`
+
+func TestParseMergedPullRequest(t *testing.T) {
+	tests := []struct {
+		event		string
+		payload		string
+		owner		string
+		commitSha	string
+		flavor		string
+	}{
+		{ CloudMergedEvent, cloudMergedPayload, "acme", "0123456789ab", CloudFlavor },
+		{ ServerMergedEvent, serverMergedPayload, "ACME", "0123456789abcdef0123456789abcdef01234567", ServerFlavor },
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set("X-Event-Key", test.event)
+		event, err := New().Parse(header, []byte(test.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event == nil {
+			t.Fatalf("%s: expected an event", test.event)
+		}
+		trigger := event.Trigger
+		if trigger.Owner != test.owner || trigger.Repository != "shop" || trigger.Branch != "main" || trigger.Name != "PR #12" {
+			t.Errorf("%s: unexpected trigger %+v", test.event, trigger)
+		}
+		if event.Number != 12 || event.CommitSha != test.commitSha || event.Metadata["flavor"] != test.flavor {
+			t.Errorf("%s: unexpected event %+v", test.event, event)
+		}
+	}
+}
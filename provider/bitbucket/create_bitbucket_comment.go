@@ -0,0 +1,62 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+const CloudApiBaseUrl = "https://api.bitbucket.org/2.0"
+
+type CloudCommentContent struct {
+	Raw	string	`json:"raw"`
+}
+
+type CloudCreateCommentPayload struct {
+	Content	CloudCommentContent	`json:"content"`
+}
+
+type ServerCreateCommentPayload struct {
+	Text	string	`json:"text"`
+}
+
+// createBitbucketComment posts on the pull request using the API of its flavor.
+//  Cloud: ["POST /repositories/{workspace}/{repo_slug}/pullrequests/{pull_request_id}/comments"]
+//  Server: ["POST /rest/api/1.0/projects/{projectKey}/repos/{repositorySlug}/pull-requests/{pullRequestId}/comments"]
+func createBitbucketComment(event *provider.Event, comment string) (map[string]interface{}, error) {
+
+	owner := event.Trigger.Owner
+	repository := event.Trigger.Repository
+
+	var url string
+	var commentPayload interface{}
+	if event.Metadata["flavor"] == ServerFlavor {
+		url = fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d/comments",
+			serverUrl(),
+			owner,
+			repository,
+			event.Number)
+		commentPayload = ServerCreateCommentPayload{
+			Text: provider.CreateComment(comment),
+		}
+	} else {
+		url = fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments",
+			CloudApiBaseUrl,
+			owner,
+			repository,
+			event.Number)
+		commentPayload = CloudCreateCommentPayload{
+			Content: CloudCommentContent{ Raw: provider.CreateComment(comment) },
+		}
+	}
+	bitbucketAccessToken := config.Get("bitbucket", "Access_Token")
+
+	logger.Debug("Creating Bitbucket comment", "owner", owner, "repository", repository, "pull_request", event.Number)
+
+	return provider.SendApiRequest(http.MethodPost, url, commentPayload, map[string]string{
+		"Accept":        "application/json",
+		"Authorization": fmt.Sprintf("Bearer %s", bitbucketAccessToken),
+	})
+}
@@ -0,0 +1,35 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type CreateCommentPayload struct {
+	Body	string	`json:"body"`
+}
+
+// createGiteaComment: ["POST /repos/{owner}/{repo}/issues/{index}/comments"]
+func createGiteaComment(owner string, repository string, pullRequestNumber int, comment string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments",
+		apiBaseUrl(),
+		owner,
+		repository,
+		pullRequestNumber)
+	giteaAccessToken := config.Get("gitea", "Access_Token")
+
+	commentPayload := CreateCommentPayload{
+		Body: provider.CreateComment(comment),
+	}
+
+	logger.Debug("Creating Gitea comment", "owner", owner, "repository", repository, "pull_request", pullRequestNumber)
+
+	return provider.SendApiRequest(http.MethodPost, url, commentPayload, map[string]string{
+		"Accept":        "application/json",
+		"Authorization": fmt.Sprintf("token %s", giteaAccessToken),
+	})
+}
@@ -0,0 +1,132 @@
+package gitea
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/provider"
+	"strings"
+)
+
+const PullRequestEvent = "pull_request"
+
+var LogType common.LogType = "Gitea"
+
+type PayloadPullRequestBase struct {
+	Ref	string	`json:"ref"`
+}
+
+type PayloadPullRequest struct {
+	Base			PayloadPullRequestBase	`json:"base"`
+	Merged			bool					`json:"merged"`
+	Number			int						`json:"number"`
+	MergeCommitSha	string					`json:"merge_commit_sha"`
+}
+
+type PayloadRepositoryOwner struct {
+	Login	string	`json:"login"`
+}
+
+type PayloadRepository struct {
+	Name	string					`json:"name"`
+	Owner	PayloadRepositoryOwner	`json:"owner"`
+	HtmlUrl	string					`json:"html_url"`
+}
+
+// WebHookPayload is the pull request payload of Gitea and Forgejo.
+type WebHookPayload struct {
+	Action      string             `json:"action"`
+	PullRequest PayloadPullRequest `json:"pull_request"`
+	Repository  PayloadRepository  `json:"repository"`
+}
+
+// Provider handles Gitea, and Forgejo which sends the same payloads under its own
+// header names.
+type Provider struct {}
+
+func New() Provider {
+	return Provider{}
+}
+
+func (p Provider) Name() string {
+	return "gitea"
+}
+
+func (p Provider) RepositoryUrl(owner string, repository string) string {
+	giteaUrl := config.Get("gitea", "Url")
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(giteaUrl, "/"), owner, repository)
+}
+
+func (p Provider) LogType() common.LogType {
+	return LogType
+}
+
+// Verify checks the hex encoded HMAC-SHA256 of X-Gitea-Signature or X-Forgejo-Signature.
+func (p Provider) Verify(header http.Header, body []byte) error {
+	signature := header.Get("X-Gitea-Signature")
+	if signature == "" {
+		signature = header.Get("X-Forgejo-Signature")
+	}
+	if signature == "" {
+		return provider.ErrNoSignature
+	}
+	if !provider.IsSignedBy(sha256.New, provider.WebHookSecrets(p.Name()), body, signature) {
+		return provider.ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parse handles merged pull requests.
+func (p Provider) Parse(header http.Header, body []byte) (*provider.Event, error) {
+	event := header.Get("X-Gitea-Event")
+	if event == "" {
+		event = header.Get("X-Forgejo-Event")
+	}
+	if event != PullRequestEvent {
+		return nil, nil
+	}
+	var webHookPayload WebHookPayload
+	if err := json.Unmarshal(body, &webHookPayload); err != nil {
+		return nil, err
+	}
+	if strings.ToUpper(webHookPayload.Action) != "CLOSED" || !webHookPayload.PullRequest.Merged {
+		return nil, nil
+	}
+	branch := webHookPayload.PullRequest.Base.Ref
+	return &provider.Event{
+		Trigger: provider.Trigger{
+			Type:          provider.PullRequestTrigger,
+			Event:         event,
+			Owner:         webHookPayload.Repository.Owner.Login,
+			Repository:    webHookPayload.Repository.Name,
+			Branch:        branch,
+			Ref:           branch,
+			RepositoryUrl: webHookPayload.Repository.HtmlUrl,
+			Name:          fmt.Sprintf("PR #%d", webHookPayload.PullRequest.Number),
+		},
+		Number:    webHookPayload.PullRequest.Number,
+		CommitSha: webHookPayload.PullRequest.MergeCommitSha,
+	}, nil
+}
+
+// PostComment comments on the merged pull request.
+func (p Provider) PostComment(event *provider.Event, comment string) (map[string]interface{}, error) {
+	if event.Number == 0 {
+		return nil, nil
+	}
+	return createGiteaComment(event.Trigger.Owner, event.Trigger.Repository, event.Number, comment)
+}
+
+// UpdateStatus does nothing, progress on Gitea is only reported through comments.
+func (p Provider) UpdateStatus(event *provider.Event, status provider.Status, description string) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+// apiBaseUrl returns the API URL of the configured Gitea or Forgejo instance.
+func apiBaseUrl() string {
+	giteaUrl := config.Get("gitea", "Url")
+	return strings.TrimSuffix(giteaUrl, "/") + "/api/v1"
+}
@@ -0,0 +1,151 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"prolific/config"
+	"prolific/provider"
+	"strings"
+	"testing"
+)
+
+const mergedPayload = `{
+	"action": "closed",
+	"pull_request": {
+		"base": { "ref": "main" },
+		"merged": true,
+		"number": 12,
+		"merge_commit_sha": "0123456789abcdef0123456789abcdef01234567"
+	},
+	"repository": {
+		"name": "shop",
+		"owner": { "login": "acme" },
+		"html_url": "https://gitea.example.com/acme/shop"
+	}
+}`
+
+func sign(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	config.Set("gitea", "WebHook_Secret", "old;new")
+	defer config.Set("gitea", "WebHook_Secret", "")
+
+	tests := []struct {
+		name	string
+		header	string
+		value	string
+		want	error
+	}{
+		{ "Gitea signature", "X-Gitea-Signature", sign("new", mergedPayload), nil },
+		{ "Forgejo signature", "X-Forgejo-Signature", sign("new", mergedPayload), nil },
+		{ "rotated secret", "X-Gitea-Signature", sign("old", mergedPayload), nil },
+		{ "unknown secret", "X-Gitea-Signature", sign("other", mergedPayload), provider.ErrInvalidSignature },
+		{ "malformed signature", "X-Gitea-Signature", "not hex", provider.ErrInvalidSignature },
+		{ "no signature", "", "", provider.ErrNoSignature },
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.header != "" {
+			header.Set(test.header, test.value)
+		}
+		if err := New().Verify(header, []byte(mergedPayload)); err != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, err)
+		}
+	}
+}
+
+func TestParseMergedPullRequest(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Forgejo-Event", PullRequestEvent)
+
+	event, err := New().Parse(header, []byte(mergedPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event == nil {
+		t.Fatal("expected an event")
+	}
+	trigger := event.Trigger
+	if trigger.Owner != "acme" || trigger.Repository != "shop" || trigger.Branch != "main" || trigger.Ref != "main" {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+	if trigger.Type != provider.PullRequestTrigger || trigger.Name != "PR #12" {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+	if event.Number != 12 || event.CommitSha != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestParseIgnoresOtherEvents(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Gitea-Event", "push")
+	if event, err := New().Parse(header, []byte(mergedPayload)); event != nil || err != nil {
+		t.Errorf("expected push to be ignored, got %v, %v", event, err)
+	}
+
+	header.Set("X-Gitea-Event", PullRequestEvent)
+	opened := strings.Replace(mergedPayload, `"closed"`, `"opened"`, 1)
+	if event, err := New().Parse(header, []byte(opened)); event != nil || err != nil {
+		t.Errorf("expected an opened pull request to be ignored, got %v, %v", event, err)
+	}
+	unmerged := strings.Replace(mergedPayload, `"merged": true`, `"merged": false`, 1)
+	if event, err := New().Parse(header, []byte(unmerged)); event != nil || err != nil {
+		t.Errorf("expected an unmerged pull request to be ignored, got %v, %v", event, err)
+	}
+}
+
+func TestPostComment(t *testing.T) {
+	var path, authorization string
+	var payload CreateCommentPayload
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		path = request.Method + " " + request.URL.Path
+		authorization = request.Header.Get("Authorization")
+		body, _ := ioutil.ReadAll(request.Body)
+		_ = json.Unmarshal(body, &payload)
+		writer.WriteHeader(http.StatusCreated)
+		_, _ = writer.Write([]byte(`{"id": 7}`))
+	}))
+	defer server.Close()
+	config.Set("gitea", "Url", server.URL + "/")
+	config.Set("gitea", "Access_Token", "secret-token")
+	defer config.Set("gitea", "Url", "")
+	defer config.Set("gitea", "Access_Token", "")
+
+	event := &provider.Event{
+		Trigger: provider.Trigger{ Owner: "acme", Repository: "shop" },
+		Number:  12,
+	}
+	apiResponse, err := New().PostComment(event, "Deployed.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "POST /api/v1/repos/acme/shop/issues/12/comments" {
+		t.Errorf("unexpected request %s", path)
+	}
+	if authorization != "token secret-token" {
+		t.Errorf("unexpected authorization %q", authorization)
+	}
+	if !strings.Contains(payload.Body, "Deployed.") {
+		t.Errorf("unexpected comment %q", payload.Body)
+	}
+	if apiResponse["id"] != float64(7) {
+		t.Errorf("unexpected response %v", apiResponse)
+	}
+}
+
+func TestPostCommentWithoutPullRequest(t *testing.T) {
+	apiResponse, err := New().PostComment(&provider.Event{}, "Deployed.")
+	if apiResponse != nil || err != nil {
+		t.Errorf("expected no comment, got %v, %v", apiResponse, err)
+	}
+}
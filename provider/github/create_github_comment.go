@@ -0,0 +1,30 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type IssueCreateCommentPayload struct {
+	Body	string	`json:"body"`
+}
+
+// createGitHubComment: ["POST /repos/{owner}/{repo}/issues/{issue_number}/comments"]
+func createGitHubComment(owner string, repository string, issueNumber int, comment string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments",
+		ApiBaseUrl,
+		owner,
+		repository,
+		issueNumber)
+
+	commentPayload := IssueCreateCommentPayload{
+		Body: provider.CreateComment(comment),
+	}
+
+	logger.Debug("Creating GitHub comment", "owner", owner, "repository", repository, "pull_request", issueNumber)
+
+	return provider.SendApiRequest(http.MethodPost, url, commentPayload, authorizationHeaders())
+}
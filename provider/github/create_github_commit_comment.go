@@ -0,0 +1,30 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type CreateCommitCommentPayload struct {
+	Body	string	`json:"body"`
+}
+
+// createGitHubCommitComment: ["POST /repos/{owner}/{repo}/commits/{commit_sha}/comments"]
+func createGitHubCommitComment(owner string, repository string, commitSha string, comment string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/commits/%s/comments",
+		ApiBaseUrl,
+		owner,
+		repository,
+		commitSha)
+
+	commentPayload := CreateCommitCommentPayload{
+		Body: provider.CreateComment(comment),
+	}
+
+	logger.Debug("Creating GitHub commit comment", "owner", owner, "repository", repository, "commit", shortSha(commitSha))
+
+	return provider.SendApiRequest(http.MethodPost, url, commentPayload, authorizationHeaders())
+}
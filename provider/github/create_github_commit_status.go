@@ -0,0 +1,32 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+const CommitStatusContext = "prolific/deploy"
+
+type CreateCommitStatusPayload struct {
+	State		string	`json:"state"`
+	TargetUrl	string	`json:"target_url,omitempty"`
+	Description	string	`json:"description,omitempty"`
+	Context		string	`json:"context"`
+}
+
+// createGitHubCommitStatus: ["POST /repos/{owner}/{repo}/statuses/{sha}"]
+func createGitHubCommitStatus(owner string, repository string, commitSha string, statusPayload CreateCommitStatusPayload) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/statuses/%s",
+		ApiBaseUrl,
+		owner,
+		repository,
+		commitSha)
+
+	logger.Debug("Creating GitHub commit status", "owner", owner, "repository", repository,
+		"commit", shortSha(commitSha), "state", statusPayload.State)
+
+	return provider.SendApiRequest(http.MethodPost, url, statusPayload, authorizationHeaders())
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type CreateDeploymentPayload struct {
+	Ref					string		`json:"ref"`
+	Task				string		`json:"task"`
+	Environment			string		`json:"environment"`
+	Description			string		`json:"description"`
+	AutoMerge			bool		`json:"auto_merge"`
+	RequiredContexts	[]string	`json:"required_contexts"`
+}
+
+type CreateDeploymentStatusPayload struct {
+	State		string	`json:"state"`
+	LogUrl		string	`json:"log_url,omitempty"`
+	Description	string	`json:"description,omitempty"`
+	Environment	string	`json:"environment,omitempty"`
+}
+
+// createGitHubDeployment: ["POST /repos/{owner}/{repo}/deployments"]
+func createGitHubDeployment(owner string, repository string, ref string, environment string, description string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/deployments",
+		ApiBaseUrl,
+		owner,
+		repository)
+
+	deploymentPayload := CreateDeploymentPayload{
+		Ref:              ref,
+		Task:             "deploy",
+		Environment:      environment,
+		Description:      description,
+		AutoMerge:        false,
+		RequiredContexts: []string{},
+	}
+
+	logger.Debug("Creating GitHub deployment", "owner", owner, "repository", repository,
+		"ref", ref, "environment", environment)
+
+	return provider.SendApiRequest(http.MethodPost, url, deploymentPayload, authorizationHeaders())
+}
+
+// createGitHubDeploymentStatus: ["POST /repos/{owner}/{repo}/deployments/{deployment_id}/statuses"]
+func createGitHubDeploymentStatus(owner string, repository string, deploymentId string, statusPayload CreateDeploymentStatusPayload) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/deployments/%s/statuses",
+		ApiBaseUrl,
+		owner,
+		repository,
+		deploymentId)
+
+	logger.Debug("Creating GitHub deployment status", "owner", owner, "repository", repository,
+		"github_deployment", deploymentId, "state", statusPayload.State)
+
+	return provider.SendApiRequest(http.MethodPost, url, statusPayload, authorizationHeaders())
+}
@@ -0,0 +1,32 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type PullCreateReviewPayload struct {
+	Event			string	`json:"event"`
+	Body			string	`json:"body"`
+}
+
+// createGitHubReview: ["POST /repos/{owner}/{repo}/pulls/{pull_number}/reviews"]
+func createGitHubReview(owner string, repository string, pullRequestNumber int, comment string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews",
+		ApiBaseUrl,
+		owner,
+		repository,
+		pullRequestNumber)
+
+	reviewPayload := PullCreateReviewPayload{
+		Event: "COMMENT",
+		Body:  provider.CreateComment(comment),
+	}
+
+	logger.Debug("Creating GitHub review", "owner", owner, "repository", repository, "pull_request", pullRequestNumber)
+
+	return provider.SendApiRequest(http.MethodPost, url, reviewPayload, authorizationHeaders())
+}
@@ -0,0 +1,28 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/provider"
+)
+
+// getGitHubCollaboratorPermission: ["GET /repos/{owner}/{repo}/collaborators/{username}/permission"]
+func getGitHubCollaboratorPermission(owner string, repository string, username string) (string, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/collaborators/%s/permission",
+		ApiBaseUrl,
+		owner,
+		repository,
+		username)
+
+	apiResponse, err := provider.SendApiRequest(http.MethodGet, url, nil, authorizationHeaders())
+	if err != nil {
+		return "", err
+	}
+	permission, ok := apiResponse["permission"].(string)
+	if !ok {
+		return "", fmt.Errorf("permission of %s on %s/%s not found: %v",
+			username, owner, repository, apiResponse["message"])
+	}
+	return permission, nil
+}
@@ -0,0 +1,37 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"prolific/provider"
+)
+
+// getGitHubPullRequest: ["GET /repos/{owner}/{repo}/pulls/{pull_number}"]
+func getGitHubPullRequest(owner string, repository string, pullRequestNumber int) (*PayloadPullRequest, error) {
+
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d",
+		ApiBaseUrl,
+		owner,
+		repository,
+		pullRequestNumber)
+
+	apiResponse, err := provider.SendApiRequest(http.MethodGet, url, nil, authorizationHeaders())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := apiResponse["number"]; !ok {
+		return nil, fmt.Errorf("pull request #%d of %s/%s not found: %v",
+			pullRequestNumber, owner, repository, apiResponse["message"])
+	}
+
+	content, err := json.Marshal(apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	var pullRequest PayloadPullRequest
+	if err = json.Unmarshal(content, &pullRequest); err != nil {
+		return nil, err
+	}
+	return &pullRequest, nil
+}
@@ -0,0 +1,398 @@
+package github
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/provider"
+	"strconv"
+	"strings"
+)
+
+const (
+	ApiBaseUrl			= "https://api.github.com"
+	PullRequestEvent	= "pull_request"
+	PushEvent			= "push"
+	CreateEvent			= "create"
+	ReleaseEvent		= "release"
+	IssueCommentEvent	= "issue_comment"
+)
+
+var LogType common.LogType = "GitHub"
+
+type PayloadPullRequestBase struct {
+	Ref	string	`json:"ref"`
+}
+
+type PayloadPullRequest struct {
+	Base           PayloadPullRequestBase `json:"base"`
+	Merged         bool                   `json:"merged"`
+	Number         int                    `json:"number"`
+	MergeCommitSha string                 `json:"merge_commit_sha"`
+}
+
+type PayloadRepositoryOwner struct {
+	Login	string	`json:"login"`
+}
+
+type PayloadRepository struct {
+	Name  string                 `json:"name"`
+	Owner PayloadRepositoryOwner `json:"owner"`
+}
+
+type WebHookPayload struct {
+	Action      string             `json:"action"`
+	PullRequest PayloadPullRequest `json:"pull_request"`
+	Repository  PayloadRepository  `json:"repository"`
+}
+
+type PushPayload struct {
+	Ref			string				`json:"ref"`
+	After		string				`json:"after"`
+	Deleted		bool				`json:"deleted"`
+	Repository	PayloadRepository	`json:"repository"`
+}
+
+type CreatePayload struct {
+	Ref			string				`json:"ref"`
+	RefType		string				`json:"ref_type"`
+	Repository	PayloadRepository	`json:"repository"`
+}
+
+type PayloadRelease struct {
+	TagName	string	`json:"tag_name"`
+}
+
+type ReleasePayload struct {
+	Action		string				`json:"action"`
+	Release		PayloadRelease		`json:"release"`
+	Repository	PayloadRepository	`json:"repository"`
+}
+
+type PayloadUser struct {
+	Login	string	`json:"login"`
+}
+
+type PayloadComment struct {
+	Body	string		`json:"body"`
+	User	PayloadUser	`json:"user"`
+}
+
+type PayloadIssue struct {
+	Number		int			`json:"number"`
+	PullRequest	*struct{}	`json:"pull_request"`
+}
+
+type IssueCommentPayload struct {
+	Action		string				`json:"action"`
+	Issue		PayloadIssue		`json:"issue"`
+	Comment		PayloadComment		`json:"comment"`
+	Repository	PayloadRepository	`json:"repository"`
+}
+
+type Provider struct {}
+
+func New() Provider {
+	return Provider{}
+}
+
+func (p Provider) Name() string {
+	return "github"
+}
+
+func (p Provider) RepositoryUrl(owner string, repository string) string {
+	return fmt.Sprintf("https://github.com/%s/%s", owner, repository)
+}
+
+func (p Provider) LogType() common.LogType {
+	return LogType
+}
+
+// Verify checks X-Hub-Signature-256, falling back to the legacy SHA-1 X-Hub-Signature
+// unless GITHUB_REQUIRE_SHA256 is enabled.
+func (p Provider) Verify(header http.Header, body []byte) error {
+	secrets := provider.WebHookSecrets(p.Name())
+
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		if !strings.HasPrefix(signature, "sha256=") ||
+			!provider.IsSignedBy(sha256.New, secrets, body, strings.TrimPrefix(signature, "sha256=")) {
+			return provider.ErrInvalidSignature
+		}
+		return nil
+	}
+
+	signature := header.Get("X-Hub-Signature")
+	if signature == "" {
+		return provider.ErrNoSignature
+	}
+	if config.GetWithDefault(p.Name(), "Require_SHA256", "false") == "true" {
+		return provider.ErrSHA1NotAccepted
+	}
+	if !strings.HasPrefix(signature, "sha1=") ||
+		!provider.IsSignedBy(sha1.New, secrets, body, strings.TrimPrefix(signature, "sha1=")) {
+		return provider.ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parse handles merged pull requests, pushes to branches, created tags, published
+// releases and commands commented on pull requests. Deliveries without an
+// X-GitHub-Event header are read as pull requests.
+func (p Provider) Parse(header http.Header, body []byte) (*provider.Event, error) {
+	event := header.Get("X-GitHub-Event")
+	switch event {
+	case "", PullRequestEvent:
+		var webHookPayload WebHookPayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		if strings.ToUpper(webHookPayload.Action) != "CLOSED" || !webHookPayload.PullRequest.Merged {
+			return nil, nil
+		}
+		branch := webHookPayload.PullRequest.Base.Ref
+		return &provider.Event{
+			Trigger: trigger(provider.PullRequestTrigger, PullRequestEvent, webHookPayload.Repository, branch,
+				fmt.Sprintf("PR #%d", webHookPayload.PullRequest.Number)),
+			Number:    webHookPayload.PullRequest.Number,
+			CommitSha: webHookPayload.PullRequest.MergeCommitSha,
+		}, nil
+	case PushEvent:
+		var webHookPayload PushPayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		if webHookPayload.Deleted || !strings.HasPrefix(webHookPayload.Ref, "refs/heads/") {
+			return nil, nil
+		}
+		branch := strings.TrimPrefix(webHookPayload.Ref, "refs/heads/")
+		commitSha := webHookPayload.After
+		return &provider.Event{
+			Trigger:   trigger(provider.PushTrigger, event, webHookPayload.Repository, branch,
+				fmt.Sprintf("push of `%s`", shortSha(commitSha))),
+			CommitSha: commitSha,
+		}, nil
+	case CreateEvent:
+		var webHookPayload CreatePayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		if webHookPayload.RefType != "tag" {
+			return nil, nil
+		}
+		tag := webHookPayload.Ref
+		return &provider.Event{
+			Trigger: trigger(provider.TagTrigger, event, webHookPayload.Repository, tag,
+				fmt.Sprintf("tag `%s`", tag)),
+		}, nil
+	case ReleaseEvent:
+		var webHookPayload ReleasePayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		if webHookPayload.Action != "published" {
+			return nil, nil
+		}
+		tag := webHookPayload.Release.TagName
+		return &provider.Event{
+			Trigger: trigger(provider.TagTrigger, event, webHookPayload.Repository, tag,
+				fmt.Sprintf("tag `%s`", tag)),
+		}, nil
+	case IssueCommentEvent:
+		var webHookPayload IssueCommentPayload
+		if err := json.Unmarshal(body, &webHookPayload); err != nil {
+			return nil, err
+		}
+		if webHookPayload.Action != "created" || webHookPayload.Issue.PullRequest == nil {
+			return nil, nil
+		}
+		command, ok := provider.ParseCommand(webHookPayload.Comment.Body)
+		if !ok {
+			return nil, nil
+		}
+		repository := webHookPayload.Repository
+		number := webHookPayload.Issue.Number
+		pullRequest, err := getGitHubPullRequest(repository.Owner.Login, repository.Name, number)
+		if err != nil {
+			return nil, err
+		}
+		commitSha := ""
+		if pullRequest.Merged {
+			commitSha = pullRequest.MergeCommitSha
+		}
+		return &provider.Event{
+			Trigger:   trigger(provider.PullRequestTrigger, event, repository, pullRequest.Base.Ref,
+				fmt.Sprintf("PR #%d", number)),
+			Number:    number,
+			CommitSha: commitSha,
+			Command:   command,
+			Actor:     webHookPayload.Comment.User.Login,
+		}, nil
+	}
+	return nil, nil
+}
+
+// CanDeploy reports whether the author of the command has write permission on the
+// repository.
+func (p Provider) CanDeploy(event *provider.Event) (bool, error) {
+	trigger := event.Trigger
+	permission, err := getGitHubCollaboratorPermission(trigger.Owner, trigger.Repository, event.Actor)
+	if err != nil {
+		return false, err
+	}
+	return permission == "admin" || permission == "write", nil
+}
+
+// PostComment replies to commands on the pull request. Otherwise, unless
+// GITHUB_CREATE_REVIEWS is disabled, it reviews the merged pull request or comments on
+// the pushed commit.
+func (p Provider) PostComment(event *provider.Event, comment string) (map[string]interface{}, error) {
+	trigger := event.Trigger
+	if event.Command != "" {
+		return createGitHubComment(trigger.Owner, trigger.Repository, event.Number, comment)
+	}
+	if config.GetWithDefault(p.Name(), "Create_Reviews", "true") != "true" {
+		return nil, nil
+	}
+	if event.Number != 0 {
+		return createGitHubReview(trigger.Owner, trigger.Repository, event.Number, comment)
+	}
+	if event.CommitSha != "" {
+		return createGitHubCommitComment(trigger.Owner, trigger.Repository, event.CommitSha, comment)
+	}
+	return nil, nil
+}
+
+// UpdateStatus reports the deployment through the Deployments API and as the
+// "prolific/deploy" commit status, unless GITHUB_CREATE_DEPLOYMENTS or
+// GITHUB_CREATE_COMMIT_STATUSES is disabled.
+func (p Provider) UpdateStatus(event *provider.Event, status provider.Status, description string) ([]map[string]interface{}, error) {
+	var apiResponses []map[string]interface{}
+	var reasons []string
+
+	if config.GetWithDefault(p.Name(), "Create_Deployments", "true") == "true" {
+		apiResponse, err := p.updateDeployment(event, status, description)
+		if apiResponse != nil {
+			apiResponses = append(apiResponses, apiResponse)
+		}
+		if err != nil {
+			reasons = append(reasons, err.Error())
+		}
+	}
+
+	if config.GetWithDefault(p.Name(), "Create_Commit_Statuses", "true") == "true" && event.CommitSha != "" {
+		trigger := event.Trigger
+		apiResponse, err := createGitHubCommitStatus(trigger.Owner, trigger.Repository, event.CommitSha,
+			CreateCommitStatusPayload{
+				State:       commitStates[status],
+				TargetUrl:   provider.LogUrl(p, event),
+				Description: description,
+				Context:     CommitStatusContext,
+			})
+		if apiResponse != nil {
+			apiResponses = append(apiResponses, apiResponse)
+		}
+		if err != nil {
+			reasons = append(reasons, err.Error())
+		}
+	}
+
+	if len(reasons) > 0 {
+		return apiResponses, errors.New(strings.Join(reasons, "; "))
+	}
+	return apiResponses, nil
+}
+
+// updateDeployment creates the deployment, with the stage as its environment, when it
+// starts running and reports its status. The deployment's ID is kept in the event's
+// "deployment_id" metadata, along with the ID of the event it was created for, so that
+// events derived from it, such as rollbacks, create their own deployment.
+func (p Provider) updateDeployment(event *provider.Event, status provider.Status, description string) (map[string]interface{}, error) {
+	trigger := event.Trigger
+
+	if event.Metadata["deployment_id"] == "" || event.Metadata["deployment_event_id"] != event.ID {
+		ref := event.CommitSha
+		if ref == "" {
+			ref = trigger.Ref
+		}
+		deployment, err := createGitHubDeployment(trigger.Owner, trigger.Repository, ref, trigger.Branch,
+			fmt.Sprintf("Deployment of %s by Prolific", trigger.Name))
+		if err != nil {
+			return nil, err
+		}
+		deploymentId, ok := deployment["id"].(float64)
+		if !ok {
+			return deployment, fmt.Errorf("deployment of %s/%s was not created: %v",
+				trigger.Owner, trigger.Repository, deployment["message"])
+		}
+		if event.Metadata == nil {
+			event.Metadata = map[string]string{}
+		}
+		event.Metadata["deployment_id"] = strconv.FormatInt(int64(deploymentId), 10)
+		event.Metadata["deployment_event_id"] = event.ID
+	}
+
+	return createGitHubDeploymentStatus(trigger.Owner, trigger.Repository, event.Metadata["deployment_id"],
+		CreateDeploymentStatusPayload{
+			State:       deploymentStates[status],
+			LogUrl:      provider.LogUrl(p, event),
+			Description: description,
+			Environment: trigger.Branch,
+		})
+}
+
+// deploymentStates maps statuses to the states of GitHub deployment statuses.
+var deploymentStates = map[provider.Status]string{
+	provider.StatusRunning:     "in_progress",
+	provider.StatusSuccess:     "success",
+	provider.StatusFailure:     "failure",
+	provider.StatusInterrupted: "error",
+}
+
+// commitStates maps statuses to the states of GitHub commit statuses.
+var commitStates = map[provider.Status]string{
+	provider.StatusRunning:     "pending",
+	provider.StatusSuccess:     "success",
+	provider.StatusFailure:     "failure",
+	provider.StatusInterrupted: "error",
+}
+
+// trigger creates the trigger of an event. The ref is also the branch, except for tags
+// whose stage is assigned by the trigger rules.
+func trigger(triggerType string, event string, payloadRepository PayloadRepository, ref string, name string) provider.Trigger {
+	owner := payloadRepository.Owner.Login
+	repository := payloadRepository.Name
+	branch := ref
+	if triggerType == provider.TagTrigger {
+		branch = ""
+	}
+	return provider.Trigger{
+		Type:          triggerType,
+		Event:         event,
+		Owner:         owner,
+		Repository:    repository,
+		Branch:        branch,
+		Ref:           ref,
+		RepositoryUrl: Provider{}.RepositoryUrl(owner, repository),
+		Name:          name,
+	}
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func authorizationHeaders() map[string]string {
+	gitHubPersonalAccessToken := config.Get("github", "Personal_Access_Token")
+	return map[string]string{
+		"Accept":        "application/vnd.github.v3+json",
+		"Authorization": fmt.Sprintf("Token %s", gitHubPersonalAccessToken),
+	}
+}
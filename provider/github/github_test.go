@@ -0,0 +1,77 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"prolific/config"
+	"prolific/provider"
+	"testing"
+)
+
+const body = `{"action":"closed"}`
+
+func sign(hashFunc func() hash.Hash, secret string) string {
+	mac := hmac.New(hashFunc, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	config.Set("github", "WebHook_Secret", "old;new")
+	defer config.Set("github", "WebHook_Secret", "")
+
+	tests := []struct {
+		name			string
+		sha256			string
+		sha1			string
+		requireSHA256	bool
+		want			error
+	}{
+		{ name: "SHA-256", sha256: "sha256=" + sign(sha256.New, "new") },
+		{ name: "SHA-256 of a rotated secret", sha256: "sha256=" + sign(sha256.New, "old") },
+		{ name: "SHA-256 of an unknown secret", sha256: "sha256=" + sign(sha256.New, "other"), want: provider.ErrInvalidSignature },
+		{ name: "SHA-256 without prefix", sha256: sign(sha256.New, "new"), want: provider.ErrInvalidSignature },
+		{ name: "SHA-256 under the SHA-1 prefix", sha256: "sha1=" + sign(sha256.New, "new"), want: provider.ErrInvalidSignature },
+		{ name: "SHA-256 preferred over an invalid SHA-1",
+			sha256: "sha256=" + sign(sha256.New, "new"), sha1: "sha1=00" },
+		{ name: "invalid SHA-256 not falling back to SHA-1",
+			sha256: "sha256=00", sha1: "sha1=" + sign(sha1.New, "new"), want: provider.ErrInvalidSignature },
+		{ name: "SHA-1", sha1: "sha1=" + sign(sha1.New, "new") },
+		{ name: "SHA-1 of a rotated secret", sha1: "sha1=" + sign(sha1.New, "old") },
+		{ name: "SHA-1 of an unknown secret", sha1: "sha1=" + sign(sha1.New, "other"), want: provider.ErrInvalidSignature },
+		{ name: "SHA-1 when SHA-256 is required",
+			sha1: "sha1=" + sign(sha1.New, "new"), requireSHA256: true, want: provider.ErrSHA1NotAccepted },
+		{ name: "SHA-256 when SHA-256 is required",
+			sha256: "sha256=" + sign(sha256.New, "new"), requireSHA256: true },
+		{ name: "no signature", want: provider.ErrNoSignature },
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.sha256 != "" {
+			header.Set("X-Hub-Signature-256", test.sha256)
+		}
+		if test.sha1 != "" {
+			header.Set("X-Hub-Signature", test.sha1)
+		}
+		config.Set("github", "Require_SHA256", "false")
+		if test.requireSHA256 {
+			config.Set("github", "Require_SHA256", "true")
+		}
+		if err := New().Verify(header, []byte(body)); err != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, err)
+		}
+	}
+	config.Set("github", "Require_SHA256", "")
+}
+
+func TestVerifyWithoutSecret(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256=" + sign(sha256.New, ""))
+	if err := New().Verify(header, []byte(body)); err != provider.ErrInvalidSignature {
+		t.Errorf("expected %v without a configured secret, got %v", provider.ErrInvalidSignature, err)
+	}
+}
@@ -0,0 +1,33 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/logger"
+	"prolific/provider"
+)
+
+type CreateNotePayload struct {
+	Body	string	`json:"body"`
+}
+
+// createGitLabNote: ["POST /projects/{id}/merge_requests/{merge_request_iid}/notes"]
+func createGitLabNote(projectId string, mergeRequestIid int, comment string) (map[string]interface{}, error) {
+
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/notes",
+		apiBaseUrl(),
+		projectId,
+		mergeRequestIid)
+	gitLabPersonalAccessToken := config.Get("gitlab", "Personal_Access_Token")
+
+	notePayload := CreateNotePayload{
+		Body: provider.CreateComment(comment),
+	}
+
+	logger.Debug("Creating GitLab note", "project", projectId, "merge_request", mergeRequestIid)
+
+	return provider.SendApiRequest(http.MethodPost, url, notePayload, map[string]string{
+		"PRIVATE-TOKEN": gitLabPersonalAccessToken,
+	})
+}
@@ -0,0 +1,131 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"prolific/config"
+	"prolific/features/common"
+	"prolific/provider"
+	"strconv"
+	"strings"
+)
+
+const MergeRequestEvent = "Merge Request Hook"
+
+var LogType common.LogType = "GitLab"
+
+type PayloadProject struct {
+	ID					int		`json:"id"`
+	PathWithNamespace	string	`json:"path_with_namespace"`
+	WebUrl				string	`json:"web_url"`
+}
+
+type PayloadMergeRequest struct {
+	IID				int		`json:"iid"`
+	Action			string	`json:"action"`
+	State			string	`json:"state"`
+	TargetBranch	string	`json:"target_branch"`
+	MergeCommitSha	string	`json:"merge_commit_sha"`
+}
+
+type WebHookPayload struct {
+	ObjectKind			string				`json:"object_kind"`
+	Project				PayloadProject		`json:"project"`
+	ObjectAttributes	PayloadMergeRequest	`json:"object_attributes"`
+}
+
+// Owner returns the namespace of the project, which may contain subgroups.
+func (webHookPayload WebHookPayload) Owner() string {
+	path := webHookPayload.Project.PathWithNamespace
+	if index := strings.LastIndex(path, "/"); index >= 0 {
+		return path[:index]
+	}
+	return ""
+}
+
+// Repository returns the path of the project without its namespace.
+func (webHookPayload WebHookPayload) Repository() string {
+	path := webHookPayload.Project.PathWithNamespace
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+type Provider struct {}
+
+func New() Provider {
+	return Provider{}
+}
+
+func (p Provider) Name() string {
+	return "gitlab"
+}
+
+func (p Provider) RepositoryUrl(owner string, repository string) string {
+	gitLabUrl := config.GetWithDefault("gitlab", "Url", "https://gitlab.com")
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(gitLabUrl, "/"), owner, repository)
+}
+
+func (p Provider) LogType() common.LogType {
+	return LogType
+}
+
+// Verify checks the secret token GitLab sends in X-Gitlab-Token.
+func (p Provider) Verify(header http.Header, body []byte) error {
+	gitLabToken := header.Get("X-Gitlab-Token")
+	if gitLabToken == "" {
+		return provider.ErrNoToken
+	}
+	if !provider.IsTokenOf(provider.WebHookSecrets(p.Name()), gitLabToken) {
+		return provider.ErrInvalidToken
+	}
+	return nil
+}
+
+// Parse handles merged merge requests.
+func (p Provider) Parse(header http.Header, body []byte) (*provider.Event, error) {
+	if header.Get("X-Gitlab-Event") != MergeRequestEvent {
+		return nil, nil
+	}
+	var webHookPayload WebHookPayload
+	if err := json.Unmarshal(body, &webHookPayload); err != nil {
+		return nil, err
+	}
+	mergeRequest := webHookPayload.ObjectAttributes
+	if webHookPayload.ObjectKind != "merge_request" || mergeRequest.Action != "merge" || mergeRequest.State != "merged" {
+		return nil, nil
+	}
+	return &provider.Event{
+		Trigger: provider.Trigger{
+			Type:          provider.PullRequestTrigger,
+			Event:         webHookPayload.ObjectKind,
+			Owner:         webHookPayload.Owner(),
+			Repository:    webHookPayload.Repository(),
+			Branch:        mergeRequest.TargetBranch,
+			Ref:           mergeRequest.TargetBranch,
+			RepositoryUrl: webHookPayload.Project.WebUrl,
+			Name:          fmt.Sprintf("MR !%d", mergeRequest.IID),
+		},
+		Number:    mergeRequest.IID,
+		CommitSha: mergeRequest.MergeCommitSha,
+		Metadata:  map[string]string{ "project_id": strconv.Itoa(webHookPayload.Project.ID) },
+	}, nil
+}
+
+// PostComment adds a note to the merged merge request.
+func (p Provider) PostComment(event *provider.Event, comment string) (map[string]interface{}, error) {
+	if event.Number == 0 {
+		return nil, nil
+	}
+	return createGitLabNote(event.Metadata["project_id"], event.Number, comment)
+}
+
+// UpdateStatus does nothing, progress on GitLab is only reported through notes.
+func (p Provider) UpdateStatus(event *provider.Event, status provider.Status, description string) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+// apiBaseUrl returns the API URL of the GitLab instance, which defaults to gitlab.com.
+func apiBaseUrl() string {
+	gitLabUrl := config.GetWithDefault("gitlab", "Url", "https://gitlab.com")
+	return strings.TrimSuffix(gitLabUrl, "/") + "/api/v4"
+}
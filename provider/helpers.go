@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"prolific/config"
+	"strings"
+	"time"
+)
+
+// WebHookSecrets returns the configured secrets of a module. Several secrets separated
+// by semicolons may be given while a secret is being rotated.
+func WebHookSecrets(moduleName string) []string {
+	var secrets []string
+	for _, secret := range strings.Split(config.Get(moduleName, "WebHook_Secret"), ";") {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// IsSignedBy reports whether the hex encoded signature is the HMAC of body under any of
+// the secrets, comparing in constant time.
+func IsSignedBy(hashFunc func() hash.Hash, secrets []string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	valid := false
+	for _, secret := range secrets {
+		mac := hmac.New(hashFunc, []byte(secret))
+		mac.Write(body)
+		if hmac.Equal(mac.Sum(nil), expected) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// IsTokenOf reports whether token is one of the secrets, comparing in constant time.
+func IsTokenOf(secrets []string, token string) bool {
+	valid := false
+	for _, secret := range secrets {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// CreateComment signs a comment with the name and URL of the server.
+func CreateComment(comment string) string {
+	serverName := config.Get("server", "name")
+	serverUrl := config.Get("server", "url")
+	return fmt.Sprintf("**[Prolific Bot]**\n\n%s\n\nAssigned Server: [%s](%s)",
+		comment, serverName, serverUrl)
+}
+
+// ParseCommand returns the command of the first line of a comment starting with
+// "/prolific", which is empty when no command follows. ok is false when the comment has
+// no such line.
+func ParseCommand(comment string) (command string, ok bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "/prolific" {
+			continue
+		}
+		if len(fields) > 1 {
+			command = strings.ToLower(fields[1])
+		}
+		return command, true
+	}
+	return "", false
+}
+
+// LogUrl returns the URL serving the log of the deployment of an event.
+func LogUrl(p Provider, event *Event) string {
+	serverUrl := strings.TrimSuffix(config.Get("server", "url"), "/")
+	return fmt.Sprintf("%s/log/%s/%s", serverUrl, p.Name(), event.ID)
+}
+
+// SendApiRequest sends a JSON request to a forge API and decodes its JSON response.
+// The headers typically carry the forge's authorization. Responses of a status other
+// than 2xx are returned as an error holding the status and the body.
+func SendApiRequest(method string, url string, payload interface{}, headers map[string]string) (map[string]interface{}, error) {
+
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := &http.Client{
+		Timeout:       15 * time.Second,
+	}
+
+	request, err := http.NewRequest(method, url, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	r, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, r.Status, strings.TrimSpace(string(data)))
+	}
+	var apiResponse map[string]interface{}
+	err = json.Unmarshal(data, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	return apiResponse, nil
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"prolific/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWebHookSecrets(t *testing.T) {
+	config.Set("test", "WebHook_Secret", ";old;;new;")
+	defer config.Set("test", "WebHook_Secret", "")
+
+	if secrets := WebHookSecrets("test"); !reflect.DeepEqual(secrets, []string{ "old", "new" }) {
+		t.Errorf("unexpected secrets %v", secrets)
+	}
+	config.Set("test", "WebHook_Secret", "")
+	if secrets := WebHookSecrets("test"); len(secrets) != 0 {
+		t.Errorf("expected no secret, got %v", secrets)
+	}
+}
+
+func TestIsSignedBy(t *testing.T) {
+	body := []byte("payload")
+	mac := hmac.New(sha256.New, []byte("new"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	if !IsSignedBy(sha256.New, []string{ "old", "new" }, body, signature) {
+		t.Error("expected the signature of the second secret to be accepted")
+	}
+	if IsSignedBy(sha256.New, []string{ "old" }, body, signature) {
+		t.Error("expected the signature of another secret to be refused")
+	}
+	if IsSignedBy(sha256.New, nil, body, signature) {
+		t.Error("expected any signature to be refused without secrets")
+	}
+	if IsSignedBy(sha256.New, []string{ "new" }, []byte("tampered"), signature) {
+		t.Error("expected the signature of another body to be refused")
+	}
+	if IsSignedBy(sha256.New, []string{ "new" }, body, "zz" + signature[2:]) {
+		t.Error("expected a malformed signature to be refused")
+	}
+}
+
+func TestIsTokenOf(t *testing.T) {
+	if !IsTokenOf([]string{ "old", "new" }, "new") {
+		t.Error("expected the second token to be accepted")
+	}
+	if IsTokenOf([]string{ "old", "new" }, "ne") {
+		t.Error("expected a prefix of a token to be refused")
+	}
+	if IsTokenOf(nil, "") {
+		t.Error("expected an empty token to be refused without secrets")
+	}
+}
+
+func TestSendApiRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer server.Close()
+
+	response, err := SendApiRequest(http.MethodPost, server.URL + "/comments", map[string]string{ "body": "hi" }, nil)
+	if err != nil || response["id"] != float64(7) {
+		t.Fatalf("expected the decoded response, got %v, %v", response, err)
+	}
+
+	_, err = SendApiRequest(http.MethodGet, server.URL + "/missing", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "Not Found") {
+		t.Fatalf("expected an error holding the status and the body, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	repositoryNamePattern	= regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	ownerNamePattern		= regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*$`)
+)
+
+// ValidateRefName checks a branch or tag name against the rules of git
+// check-ref-format, additionally refusing names beginning with a dash so that a name is
+// never mistaken for an option.
+func ValidateRefName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty name")
+	case name == "@":
+		return errors.New("name is @")
+	case strings.HasPrefix(name, "-"):
+		return errors.New("name begins with a dash")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return errors.New("name begins or ends with a slash")
+	case strings.HasSuffix(name, "."):
+		return errors.New("name ends with a dot")
+	case strings.Contains(name, "//"):
+		return errors.New("name contains consecutive slashes")
+	case strings.Contains(name, ".."):
+		return errors.New("name contains two consecutive dots")
+	case strings.Contains(name, "@{"):
+		return errors.New("name contains @{")
+	}
+	for _, character := range name {
+		if character < 0x20 || character == 0x7f || strings.ContainsRune(" ~^:?*[\\", character) {
+			return fmt.Errorf("name contains %q", character)
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return errors.New("a component of the name begins with a dot")
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return errors.New("a component of the name ends with .lock")
+		}
+	}
+	return nil
+}
+
+// ValidateRepositoryName checks that a repository name is a single path component made
+// of letters, digits, dots, dashes and underscores.
+func ValidateRepositoryName(name string) error {
+	if !repositoryNamePattern.MatchString(name) || name == "." || name == ".." {
+		return errors.New("name must only contain letters, digits, dots, dashes and underscores")
+	}
+	return nil
+}
+
+// ValidateOwnerName checks an owner name like a repository name, allowing the slashes
+// separating nested groups.
+func ValidateOwnerName(name string) error {
+	if !ownerNamePattern.MatchString(name) {
+		return errors.New("name must only contain letters, digits, dots, dashes, underscores and slashes")
+	}
+	for _, component := range strings.Split(name, "/") {
+		if component == "." || component == ".." {
+			return errors.New("name must not contain . or .. components")
+		}
+	}
+	return nil
+}
+
+// Validate checks the names of the trigger before anything is run with them. Its ref
+// must be a valid ref name, which commit hashes are.
+func (trigger Trigger) Validate() error {
+	if err := ValidateOwnerName(trigger.Owner); err != nil {
+		return fmt.Errorf("invalid owner %q: %s", trigger.Owner, err.Error())
+	}
+	if err := ValidateRepositoryName(trigger.Repository); err != nil {
+		return fmt.Errorf("invalid repository %q: %s", trigger.Repository, err.Error())
+	}
+	if err := ValidateRefName(trigger.Branch); err != nil {
+		return fmt.Errorf("invalid branch %q: %s", trigger.Branch, err.Error())
+	}
+	if err := ValidateRefName(trigger.Ref); err != nil {
+		return fmt.Errorf("invalid ref %q: %s", trigger.Ref, err.Error())
+	}
+	return nil
+}
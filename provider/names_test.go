@@ -0,0 +1,99 @@
+package provider
+
+import "testing"
+
+func TestValidateRefName(t *testing.T) {
+	valid := []string{
+		"main",
+		"release/1.0",
+		"feature/a-b_c.d",
+		"v1.2.3",
+		"0123456789abcdef0123456789abcdef01234567",
+		"a@b",
+		// Git accepts shell syntax in names, which steps are run without.
+		"r;x",
+		"r$(id)",
+	}
+	for _, name := range valid {
+		if err := ValidateRefName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"@",
+		"-x",
+		"--upload-pack=touch",
+		"/main",
+		"main/",
+		"main.",
+		"a//b",
+		"a..b",
+		"a@{1}",
+		"a b",
+		"a~1",
+		"a^",
+		"a:b",
+		"a?",
+		"a*",
+		"a[b",
+		"a\\b",
+		"a\x00b",
+		"a\nb",
+		"a\x7fb",
+		".hidden",
+		"a/.hidden",
+		"main.lock",
+		"a.lock/b",
+	}
+	for _, name := range invalid {
+		if err := ValidateRefName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestValidateRepositoryName(t *testing.T) {
+	for _, name := range []string{ "shop", "shop.api", "shop-api", "shop_api", "Shop2" } {
+		if err := ValidateRepositoryName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{ "", ".", "..", "a/b", "../shop", "shop api", "shop;x", "shop$(id)" } {
+		if err := ValidateRepositoryName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestValidateOwnerName(t *testing.T) {
+	for _, name := range []string{ "acme", "acme-corp", "group/subgroup", "a.b/c_d/e" } {
+		if err := ValidateOwnerName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{ "", "/acme", "acme/", "a//b", "..", "acme/..", "./acme", "ac me", "acme;x" } {
+		if err := ValidateOwnerName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestTriggerValidate(t *testing.T) {
+	trigger := Trigger{ Owner: "acme", Repository: "shop", Branch: "main", Ref: "v1.0" }
+	if err := trigger.Validate(); err != nil {
+		t.Fatalf("expected the trigger to be valid, got %v", err)
+	}
+
+	invalid := trigger
+	invalid.Ref = "--upload-pack=touch"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected a ref beginning with a dash to be refused")
+	}
+	invalid = trigger
+	invalid.Repository = "../shop"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected a repository leaving its directory to be refused")
+	}
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"prolific/features/common"
+)
+
+const (
+	PullRequestTrigger	= "pull_request"
+	PushTrigger			= "push"
+	TagTrigger			= "tag"
+	ManualTrigger		= "manual"
+	RollbackTrigger		= "rollback"
+)
+
+// Commands accepted in "/prolific <command>" comments.
+const (
+	CommandDeploy	= "deploy"
+	CommandRedeploy	= "redeploy"
+	CommandRollback	= "rollback"
+	CommandStatus	= "status"
+)
+
+type Status string
+
+const (
+	StatusRunning		Status = "running"
+	StatusSuccess		Status = "success"
+	StatusFailure		Status = "failure"
+	StatusInterrupted	Status = "interrupted"
+)
+
+var (
+	ErrNoSignature		= errors.New("no signature provided")
+	ErrInvalidSignature	= errors.New("invalid signature provided")
+	ErrSHA1NotAccepted	= errors.New("SHA-1 signature not accepted")
+	ErrNoToken			= errors.New("no token provided")
+	ErrInvalidToken		= errors.New("invalid token provided")
+)
+
+// Provider is a Git forge sending webhooks to Prolific and receiving its reports.
+type Provider interface {
+	// Name is the path of the provider's endpoints and its configuration module, such
+	// as "github".
+	Name() string
+	// RepositoryUrl is the web page of a repository of the forge.
+	RepositoryUrl(owner string, repository string) string
+	// LogType is where deployments triggered by the provider are logged. Logs are served
+	// under "/log/<name>".
+	LogType() common.LogType
+	// Verify checks that a webhook delivery comes from the forge.
+	Verify(header http.Header, body []byte) error
+	// Parse turns a verified webhook delivery into an event, or returns nil when the
+	// delivery does not describe a deployable event.
+	Parse(header http.Header, body []byte) (*Event, error)
+	// PostComment comments on what triggered the event and returns the decoded API
+	// response, or nil when there is nothing to comment on.
+	PostComment(event *Event, comment string) (map[string]interface{}, error)
+	// UpdateStatus reports the state of the deployment of the event and returns the
+	// decoded API responses, or nil when the provider does not report statuses.
+	UpdateStatus(event *Event, status Status, description string) ([]map[string]interface{}, error)
+}
+
+// Commander is implemented by providers accepting commands in pull request comments.
+type Commander interface {
+	// CanDeploy reports whether the author of a command may deploy the repository.
+	CanDeploy(event *Event) (bool, error)
+}
+
+// Trigger describes what caused a deployment, whichever forge it comes from.
+type Trigger struct {
+	// Type is one of PullRequestTrigger, PushTrigger, TagTrigger, ManualTrigger or
+	// RollbackTrigger.
+	Type			string	`json:"type"`
+	// Event is the forge event the trigger was parsed from, such as "release".
+	Event			string	`json:"event,omitempty"`
+	Owner			string	`json:"owner"`
+	Repository		string	`json:"repository"`
+	// Branch is the stage deployed to, which names the directory of the working tree.
+	// It is empty for tags until the trigger rules assign a stage.
+	Branch			string	`json:"branch"`
+	// Ref is the branch, tag or commit checked out.
+	Ref				string	`json:"ref"`
+	RepositoryUrl	string	`json:"repository_url"`
+	// Name is how the trigger is referred to in comments, such as "PR #12" or "MR !12".
+	Name			string	`json:"name"`
+}
+
+// Event is a deployable event, as queued. Number and CommitSha locate where to report
+// progress, and Metadata holds whatever else the provider needs to do so. ID is the ID
+// of the deployment, which is also the ID of its log, once the event is dequeued.
+// Actor is who asked for the deployment, if anyone did, and Reason why. Command is set
+// when the event is a command commented by Actor. Rollbacks revert the deployment
+// RollbackOf, by switching back to Release when it is still available. Delivery is the
+// ID of the webhook delivery the event came from, if any.
+type Event struct {
+	ID			string				`json:"id,omitempty"`
+	Trigger		Trigger				`json:"trigger"`
+	Number		int					`json:"number,omitempty"`
+	CommitSha	string				`json:"commit_sha,omitempty"`
+	Command		string				`json:"command,omitempty"`
+	Actor		string				`json:"actor,omitempty"`
+	Reason		string				`json:"reason,omitempty"`
+	RollbackOf	string				`json:"rollback_of,omitempty"`
+	Release		string				`json:"release,omitempty"`
+	Metadata	map[string]string	`json:"metadata,omitempty"`
+	Delivery	string				`json:"delivery,omitempty"`
+}
@@ -0,0 +1,344 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"prolific/logger"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued	Status = "queued"
+	StatusRunning	Status = "running"
+)
+
+// Job is a unit of work waiting in, or taken from, the queue. Jobs sharing the same Key
+// never run at the same time.
+type Job struct {
+	ID			string			`json:"id"`
+	Kind		string			`json:"kind"`
+	Key			string			`json:"key"`
+	Status		Status			`json:"status"`
+	QueuedAt	string			`json:"queued_at"`
+	StartedAt	string			`json:"started_at,omitempty"`
+	Payload		json.RawMessage	`json:"payload"`
+}
+
+// Handler processes a job of a registered kind. The context is cancelled when the queue
+// is stopped before the job could finish.
+type Handler func(ctx context.Context, job *Job) error
+
+type Queue struct {
+	mutex		sync.Mutex
+	cond		*sync.Cond
+	filePath	string
+	handlers	map[string]Handler
+	jobs		[]*Job
+	busyKeys	map[string]bool
+	started		bool
+	stopping	bool
+	running		sync.WaitGroup
+	ctx			context.Context
+	cancel		context.CancelFunc
+}
+
+// ErrPending is returned by EnqueueUnlessPending when a job of the key is pending.
+var ErrPending = errors.New("a job of the key is already pending")
+
+var logDirPath = filepath.Join("logs")
+var defaultQueue = New(filepath.Join(logDirPath, "Queue.json"))
+
+// New creates a queue persisted at filePath, restoring the jobs left over by the
+// previous run.
+func New(filePath string) *Queue {
+	queue := &Queue{
+		filePath: filePath,
+		handlers: map[string]Handler{},
+		jobs:     []*Job{},
+		busyKeys: map[string]bool{},
+	}
+	queue.cond = sync.NewCond(&queue.mutex)
+	queue.ctx, queue.cancel = context.WithCancel(context.Background())
+	queue.restore()
+	return queue
+}
+
+// Register sets the handler for jobs of the given kind on the default queue.
+func Register(kind string, handler Handler) {
+	defaultQueue.Register(kind, handler)
+}
+
+// Enqueue adds a job to the default queue.
+func Enqueue(kind string, key string, payload interface{}) (*Job, error) {
+	return defaultQueue.Enqueue(kind, key, payload)
+}
+
+// EnqueueUnlessPending adds a job to the default queue unless one of its key is pending.
+func EnqueueUnlessPending(kind string, key string, payload interface{}) (*Job, error) {
+	return defaultQueue.EnqueueUnlessPending(kind, key, payload)
+}
+
+// Pending returns the queued and running jobs of the default queue with the given key.
+func Pending(key string) []Job {
+	return defaultQueue.Pending(key)
+}
+
+// Find returns the queued or running job of the default queue with the given ID.
+func Find(id string) *Job {
+	return defaultQueue.Find(id)
+}
+
+// Start starts the workers of the default queue.
+func Start(workers int) {
+	defaultQueue.Start(workers)
+}
+
+// Stop stops the default queue. See Queue.Stop.
+func Stop(ctx context.Context) {
+	defaultQueue.Stop(ctx)
+}
+
+func (queue *Queue) Register(kind string, handler Handler) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.handlers[kind] = handler
+}
+
+func (queue *Queue) Enqueue(kind string, key string, payload interface{}) (*Job, error) {
+	return queue.enqueue(kind, key, payload, false)
+}
+
+// EnqueueUnlessPending adds a job unless a job with the same key is queued or running,
+// in which case it returns ErrPending. Both are checked and done at once, so that two
+// callers never both enqueue a job.
+func (queue *Queue) EnqueueUnlessPending(kind string, key string, payload interface{}) (*Job, error) {
+	return queue.enqueue(kind, key, payload, true)
+}
+
+func (queue *Queue) enqueue(kind string, key string, payload interface{}, unlessPending bool) (*Job, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:       id,
+		Kind:     kind,
+		Key:      key,
+		Status:   StatusQueued,
+		QueuedAt: time.Now().Format(time.RFC1123),
+		Payload:  content,
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if unlessPending {
+		for _, pending := range queue.jobs {
+			if pending.Key == key {
+				return nil, ErrPending
+			}
+		}
+	}
+	queue.jobs = append(queue.jobs, job)
+	if err = queue.persist(); err != nil {
+		queue.jobs = queue.jobs[:len(queue.jobs)-1]
+		return nil, err
+	}
+	logger.Debug("Job queued", "job", job.ID, "key", job.Key)
+	queue.cond.Broadcast()
+	return job, nil
+}
+
+// Pending returns copies of the queued and running jobs with the given key, oldest first.
+func (queue *Queue) Pending(key string) []Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	var jobs []Job
+	for _, job := range queue.jobs {
+		if job.Key == key {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// Find returns a copy of the queued or running job with the given ID, or nil when there
+// is none.
+func (queue *Queue) Find(id string) *Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for _, job := range queue.jobs {
+		if job.ID == id {
+			found := *job
+			return &found
+		}
+	}
+	return nil
+}
+
+func (queue *Queue) Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.started {
+		return
+	}
+	queue.started = true
+	for i := 0; i < workers; i++ {
+		go queue.work()
+	}
+	logger.Info("Job queue started", "workers", workers, "pending", len(queue.jobs))
+}
+
+// Stop prevents workers from taking new jobs and waits for the running ones to finish.
+// When ctx is done first, the running jobs are cancelled and Stop waits for their
+// handlers to return. Jobs not yet started stay persisted for the next run.
+func (queue *Queue) Stop(ctx context.Context) {
+	queue.mutex.Lock()
+	queue.stopping = true
+	queue.cond.Broadcast()
+	queue.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		queue.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Job queue stopped")
+	case <-ctx.Done():
+		logger.Warn("Job queue stop timed out, interrupting running jobs")
+		queue.cancel()
+		<-done
+		logger.Warn("Job queue stopped with interrupted jobs")
+	}
+}
+
+func (queue *Queue) work() {
+	for {
+		queue.mutex.Lock()
+		job := queue.next()
+		for job == nil && !queue.stopping {
+			queue.cond.Wait()
+			job = queue.next()
+		}
+		if queue.stopping {
+			queue.mutex.Unlock()
+			return
+		}
+		queue.running.Add(1)
+		job.Status = StatusRunning
+		job.StartedAt = time.Now().Format(time.RFC1123)
+		queue.busyKeys[job.Key] = true
+		if err := queue.persist(); err != nil {
+			logger.Error("Job queue persistence error", "file", queue.filePath, "error", err)
+		}
+		handler := queue.handlers[job.Kind]
+		queue.mutex.Unlock()
+
+		logger.Info("Job started", "job", job.ID, "key", job.Key)
+		var err error
+		if handler == nil {
+			logger.Error("Job has no handler for its kind", "job", job.ID, "kind", job.Kind)
+		} else {
+			err = handler(queue.ctx, job)
+		}
+		if err != nil {
+			logger.Error("Job finished with error", "job", job.ID, "key", job.Key, "error", err)
+		} else {
+			logger.Info("Job finished", "job", job.ID, "key", job.Key)
+		}
+
+		queue.mutex.Lock()
+		queue.remove(job)
+		delete(queue.busyKeys, job.Key)
+		if err := queue.persist(); err != nil {
+			logger.Error("Job queue persistence error", "file", queue.filePath, "error", err)
+		}
+		queue.cond.Broadcast()
+		queue.mutex.Unlock()
+		queue.running.Done()
+	}
+}
+
+// next returns the oldest queued job whose key is not already being worked on. The
+// caller must hold the mutex.
+func (queue *Queue) next() *Job {
+	for _, job := range queue.jobs {
+		if job.Status == StatusQueued && !queue.busyKeys[job.Key] {
+			return job
+		}
+	}
+	return nil
+}
+
+func (queue *Queue) remove(job *Job) {
+	for index, j := range queue.jobs {
+		if j == job {
+			queue.jobs = append(queue.jobs[:index], queue.jobs[index+1:]...)
+			return
+		}
+	}
+}
+
+// restore loads the jobs left over by the previous run. Jobs that were running when the
+// previous run stopped are queued again.
+func (queue *Queue) restore() {
+	content, err := ioutil.ReadFile(queue.filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Job queue persistence error", "file", queue.filePath, "error", err)
+		}
+		return
+	}
+	var jobs []*Job
+	if err = json.Unmarshal(content, &jobs); err != nil {
+		logger.Error("Job queue persistence error", "file", queue.filePath, "error", err)
+		return
+	}
+	for _, job := range jobs {
+		job.Status = StatusQueued
+		job.StartedAt = ""
+	}
+	queue.jobs = jobs
+}
+
+// persist writes the pending jobs to disk. The caller must hold the mutex.
+func (queue *Queue) persist() error {
+	if err := os.MkdirAll(filepath.Dir(queue.filePath), os.ModePerm); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(queue.jobs, "", "\t")
+	if err != nil {
+		return err
+	}
+	temporaryFilePath := queue.filePath + ".tmp"
+	if err = ioutil.WriteFile(temporaryFilePath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporaryFilePath, queue.filePath)
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestQueue(t *testing.T) (*Queue, string) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filePath := filepath.Join(dir, "Queue.json")
+	return New(filePath), filePath
+}
+
+func TestJobsOfAKeyRunOneAtATime(t *testing.T) {
+	queue, _ := newTestQueue(t)
+
+	var mutex sync.Mutex
+	running := map[string]int{}
+	overlapped := map[string]bool{}
+	var order []string
+	var finished sync.WaitGroup
+	queue.Register("test", func(ctx context.Context, job *Job) error {
+		mutex.Lock()
+		running[job.Key]++
+		if running[job.Key] > 1 {
+			overlapped[job.Key] = true
+		}
+		order = append(order, job.ID)
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		running[job.Key]--
+		mutex.Unlock()
+		finished.Done()
+		return nil
+	})
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		for _, key := range []string{ "a", "b" } {
+			finished.Add(1)
+			job, err := queue.Enqueue("test", key, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key == "a" {
+				ids = append(ids, job.ID)
+			}
+		}
+	}
+	queue.Start(4)
+	finished.Wait()
+	queue.Stop(context.Background())
+
+	if overlapped["a"] || overlapped["b"] {
+		t.Fatalf("jobs of the same key overlapped: %v", overlapped)
+	}
+	var orderOfA []string
+	for _, id := range order {
+		for _, idOfA := range ids {
+			if id == idOfA {
+				orderOfA = append(orderOfA, id)
+			}
+		}
+	}
+	for index := range ids {
+		if orderOfA[index] != ids[index] {
+			t.Fatalf("jobs of key a ran in order %v, queued in order %v", orderOfA, ids)
+		}
+	}
+}
+
+func TestJobsOfDifferentKeysRunConcurrently(t *testing.T) {
+	queue, _ := newTestQueue(t)
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	queue.Register("test", func(ctx context.Context, job *Job) error {
+		started <- job.Key
+		<-release
+		return nil
+	})
+	for _, key := range []string{ "a", "b" } {
+		if _, err := queue.Enqueue("test", key, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue.Start(2)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("jobs of different keys did not run at the same time")
+		}
+	}
+	close(release)
+	queue.Stop(context.Background())
+}
+
+func TestPendingJobsAreRestored(t *testing.T) {
+	queue, filePath := newTestQueue(t)
+
+	started := make(chan struct{})
+	queue.Register("test", func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	running, err := queue.Enqueue("test", "a", "running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := queue.Enqueue("test", "a", "queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.Start(1)
+	<-started
+
+	// The file is what a crash would leave: the first job running, the second queued.
+	restored := New(filePath)
+	jobs := restored.Pending("a")
+	if len(jobs) != 2 || jobs[0].ID != running.ID || jobs[1].ID != queued.ID {
+		t.Fatalf("expected both jobs to be restored in order, got %+v", jobs)
+	}
+	for _, job := range jobs {
+		if job.Status != StatusQueued || job.StartedAt != "" {
+			t.Errorf("expected job %s to be queued again, got %+v", job.ID, job)
+		}
+	}
+	if string(jobs[1].Payload) != `"queued"` {
+		t.Errorf("expected the payload to be restored, got %s", jobs[1].Payload)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	queue.Stop(ctx)
+}
+
+func TestStopKeepsQueuedJobs(t *testing.T) {
+	queue, filePath := newTestQueue(t)
+	queue.Register("test", func(ctx context.Context, job *Job) error {
+		return nil
+	})
+	queue.Start(1)
+	queue.Stop(context.Background())
+
+	job, err := queue.Enqueue("test", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := New(filePath).Find(job.ID); restored == nil {
+		t.Fatal("expected the job queued after Stop to be kept for the next run")
+	}
+}
+
+func TestEnqueueUnlessPending(t *testing.T) {
+	queue, _ := newTestQueue(t)
+
+	var enqueued sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		enqueued.Add(1)
+		go func() {
+			defer enqueued.Done()
+			_, err := queue.EnqueueUnlessPending("test", "a", nil)
+			if err != nil && err != ErrPending {
+				t.Error(err)
+			}
+			if err == nil {
+				mutex.Lock()
+				accepted++
+				mutex.Unlock()
+			}
+		}()
+	}
+	enqueued.Wait()
+	if accepted != 1 {
+		t.Fatalf("expected a single job to be queued, got %d", accepted)
+	}
+
+	if _, err := queue.EnqueueUnlessPending("test", "b", nil); err != nil {
+		t.Fatalf("expected a job of another key to be queued, got %v", err)
+	}
+}